@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// adEdgeEntries is the number of entries at the start and at the end of a subtitle file in which
+	// the generic built-in ad patterns are applied, as credits are found there.
+	adEdgeEntries = 10
+)
+
+var (
+	// builtinAdPatterns match advertisement entries of downloaded subtitles, which are unlikely
+	// to be dialogue anywhere in the file.
+	builtinAdPatterns = []string{
+		`(?i)\bbecome\s+(a\s+)?vip\s+member\b`,
+		`(?i)\bplease\s+rate\s+this\s+subtitle\b`,
+		`(?i)\badvertise\s+your\s+(product|brand)\b`,
+		`(?i)\bopensubtitles\b`,
+	}
+
+	// builtinEdgeAdPatterns match credit and advertisement entries which may also be dialogue,
+	// and so are only applied to the entries at the edges of the file.
+	builtinEdgeAdPatterns = []string{
+		`(?i)\bdownloaded\s+(from|at)\b`,
+		`(?i)\bsupport\s+us\b`,
+		`(?i)\b(re)?sync(ed|hronized)?\s+(and\s+corrected\s+)?by\b`,
+		`(?i)\b(corrected|ripped|encoded|subtitled|subtitles|subs|captioned|captions)\s+by\b`,
+		`(?i)(\bhttps?://|\bwww\.)\S+`,
+	}
+
+	markupRegexp = regexp.MustCompile(`<[^>]*>|\{[^}]*\}`)
+
+	builtinAdDetector = newBuiltinAdDetector()
+)
+
+// AdDetector flags advertisement and credit entries, such as "Downloaded from ..."
+// or "Synced and corrected by ...", using a set of regular expression patterns.
+type AdDetector struct {
+	// patterns are applied to all entries, and edgePatterns only to the first and last
+	// adEdgeEntries entries.
+	patterns     []*regexp.Regexp
+	edgePatterns []*regexp.Regexp
+}
+
+// NewAdDetector creates an AdDetector using the built-in patterns, as well as the patterns
+// from the given pattern file, if not empty, which are applied to all entries. The pattern
+// file lists one regular expression per line, with empty lines and lines starting with "#" ignored.
+func NewAdDetector(patternFile string) (*AdDetector, error) {
+	d := newBuiltinAdDetector()
+	if patternFile == "" {
+		return d, nil
+	}
+
+	file, err := os.Open(patternFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern in %s, line %d: %v", patternFile, lineNumber, err)
+		}
+
+		d.patterns = append(d.patterns, pattern)
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	return d, nil
+}
+
+// newBuiltinAdDetector creates an AdDetector using the built-in patterns only.
+func newBuiltinAdDetector() *AdDetector {
+	d := &AdDetector{
+		patterns:     make([]*regexp.Regexp, 0, len(builtinAdPatterns)),
+		edgePatterns: make([]*regexp.Regexp, 0, len(builtinEdgeAdPatterns)),
+	}
+
+	for _, pattern := range builtinAdPatterns {
+		d.patterns = append(d.patterns, regexp.MustCompile(pattern))
+	}
+	for _, pattern := range builtinEdgeAdPatterns {
+		d.edgePatterns = append(d.edgePatterns, regexp.MustCompile(pattern))
+	}
+
+	return d
+}
+
+// IsAd determines whether the entry at the given position of the subtitle file is an
+// advertisement or credit entry.
+func (d *AdDetector) IsAd(subtitle *SubtitleFile, i int) bool {
+	text := markupRegexp.ReplaceAllString(strings.Join(subtitle.Entries[i].Text, " "), "")
+	if matchesAny(d.patterns, text) {
+		return true
+	}
+
+	edge := i < adEdgeEntries || i >= len(subtitle.Entries)-adEdgeEntries
+	return edge && matchesAny(d.edgePatterns, text)
+}
+
+// Ads flags the advertisement and credit entries of the given subtitle file, by position.
+// A nil detector flags no entries, and returns nil.
+func (d *AdDetector) Ads(subtitle *SubtitleFile) []bool {
+	if d == nil {
+		return nil
+	}
+
+	ads := make([]bool, len(subtitle.Entries))
+	for i := range subtitle.Entries {
+		ads[i] = d.IsAd(subtitle, i)
+	}
+
+	return ads
+}
+
+// matchesAny determines whether any of the given patterns matches the given text.
+func matchesAny(patterns []*regexp.Regexp, text string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Remove removes all advertisement and credit entries from the given subtitle file, renumbers
+// the remaining entries, and returns the removed ones.
+func (d *AdDetector) Remove(subtitle *SubtitleFile) []*SubtitleEntry {
+	ads := d.Ads(subtitle)
+	removed := make([]*SubtitleEntry, 0, 2)
+	i := 0
+	subtitle.filter(func(entry *SubtitleEntry) bool {
+		ad := ads[i]
+		i++
+		if ad {
+			removed = append(removed, entry)
+			return false
+		}
+		return true
+	})
+
+	return removed
+}
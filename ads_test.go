@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestAdDetectorIsAd(t *testing.T) {
+	detector, err := NewAdDetector("")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating detector, got error: %v", err)
+	}
+
+	cases := []struct {
+		text []string
+		isAd bool
+	}{
+		{[]string{"Downloaded from", "YTS.MX"}, true},
+		{[]string{"Support us and become VIP member", "to remove all ads from www.OpenSubtitles.org"}, true},
+		{[]string{"Synced and corrected by VitoSilans"}, true},
+		{[]string{"<font color=\"#ffff00\">Subtitles by</font> SubsTeam"}, true},
+		{[]string{"Where were you?"}, false},
+		{[]string{"I downloaded the files yesterday."}, false},
+		{[]string{"They synced our phones."}, false},
+	}
+
+	for i, c := range cases {
+		subtitle := &SubtitleFile{Entries: []*SubtitleEntry{{Index: 1, Text: c.text}}}
+		if detector.IsAd(subtitle, 0) != c.isAd {
+			t.Errorf("Expected IsAd to be %v (case %d): %q", c.isAd, i, c.text)
+		}
+	}
+}
+
+func TestAdDetectorIsAdWithinFile(t *testing.T) {
+	detector, err := NewAdDetector("")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating detector, got error: %v", err)
+	}
+
+	subtitle := &SubtitleFile{}
+	for i := 0; i < 3*adEdgeEntries; i++ {
+		subtitle.Entries = append(subtitle.Entries, &SubtitleEntry{Index: i + 1, Text: []string{"Where were you?"}})
+	}
+
+	cases := []struct {
+		position int
+		text     string
+		isAd     bool
+	}{
+		{0, "Support us and become a member", true},
+		{len(subtitle.Entries) - 1, "Synced by VitoSilans, www.example.com", true},
+		{adEdgeEntries, "Nobody will support us now.", false},
+		{adEdgeEntries, "It's on www.example.com, I swear.", false},
+		{adEdgeEntries, "Become a VIP member, and remove all ads", true},
+	}
+
+	for i, c := range cases {
+		entry := subtitle.Entries[c.position]
+		original := entry.Text
+		entry.Text = []string{c.text}
+		if detector.IsAd(subtitle, c.position) != c.isAd {
+			t.Errorf("Expected IsAd to be %v (case %d): %q", c.isAd, i, c.text)
+		}
+		entry.Text = original
+	}
+}
+
+func TestAdDetectorPatternFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "subsyncer-ads")
+	if err != nil {
+		t.Fatalf("Failed to create pattern file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString("# Custom patterns\n\n(?i)^brought to you by\n")
+	file.Close()
+
+	detector, err := NewAdDetector(file.Name())
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating detector, got error: %v", err)
+	}
+
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Text: []string{"Brought to you by ACME"}},
+			{Index: 2, Text: []string{"Where were you?"}},
+			{Index: 3, Text: []string{"Downloaded from somewhere"}},
+		},
+	}
+
+	removed := detector.Remove(subtitle)
+
+	if len(removed) != 2 || removed[0].Index != 1 || removed[1].Index != 3 {
+		t.Errorf("Expected entries 1 and 3 to be removed, got %v", removed)
+	}
+
+	if len(subtitle.Entries) != 1 {
+		t.Fatalf("Expected 1 entry to remain, got %d", len(subtitle.Entries))
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "0s", "0s", "Where were you?")
+}
+
+func TestAdDetectorInvalidPatternFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "subsyncer-ads")
+	if err != nil {
+		t.Fatalf("Failed to create pattern file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString("[unclosed\n")
+	file.Close()
+
+	_, err = NewAdDetector(file.Name())
+	if err == nil {
+		t.Errorf("Expected an error to occur while loading an invalid pattern file")
+	}
+}
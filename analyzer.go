@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/lang/bg"
+	"github.com/blevesearch/bleve/analysis/lang/ca"
+	"github.com/blevesearch/bleve/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/analysis/lang/ckb"
+	"github.com/blevesearch/bleve/analysis/lang/cs"
+	"github.com/blevesearch/bleve/analysis/lang/de"
+	"github.com/blevesearch/bleve/analysis/lang/el"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/analysis/lang/es"
+	"github.com/blevesearch/bleve/analysis/lang/eu"
+	"github.com/blevesearch/bleve/analysis/lang/fr"
+	"github.com/blevesearch/bleve/analysis/lang/ga"
+	"github.com/blevesearch/bleve/analysis/lang/gl"
+	"github.com/blevesearch/bleve/analysis/lang/hy"
+	"github.com/blevesearch/bleve/analysis/lang/id"
+	"github.com/blevesearch/bleve/analysis/lang/it"
+	"github.com/blevesearch/bleve/analysis/lang/pt"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/mapping"
+)
+
+const (
+	// fallbackAnalyzerName is the analyzer used for languages with no dedicated analyzer.
+	// Unlike bleve's standard analyzer, it does not remove English stop words.
+	fallbackAnalyzerName = "subsyncer_fallback"
+)
+
+var (
+	// languageAnalyzers maps language codes to bleve analyzers providing stemming and stop-word removal.
+	// The Arabic and Persian analyzers are not used, as they depend on golang.org/x/text, which is not vendored.
+	languageAnalyzers = map[string]string{
+		"ckb": ckb.AnalyzerName,
+		"de":  de.AnalyzerName,
+		"en":  en.AnalyzerName,
+		"es":  es.AnalyzerName,
+		"fr":  fr.AnalyzerName,
+		"it":  it.AnalyzerName,
+		"pt":  pt.AnalyzerName,
+		"ja":  cjk.AnalyzerName,
+		"ko":  cjk.AnalyzerName,
+		"zh":  cjk.AnalyzerName,
+	}
+
+	// languageStopFilters maps language codes with no dedicated bleve analyzer to the
+	// token filters removing their stop words.
+	languageStopFilters = map[string][]string{
+		"bg": {bg.StopName},
+		"ca": {ca.ElisionName, ca.StopName},
+		"cs": {cs.StopName},
+		"el": {el.StopName},
+		"eu": {eu.StopName},
+		"ga": {ga.ElisionName, ga.StopName},
+		"gl": {gl.StopName},
+		"hy": {hy.StopName},
+		"id": {id.StopName},
+	}
+)
+
+// newIndexMapping creates an index mapping which analyzes text using the analyzer
+// for the given language, given in any form accepted by ParseLanguage.
+func newIndexMapping(language string) (*mapping.IndexMappingImpl, error) {
+	indexMapping := bleve.NewIndexMapping()
+
+	name, err := addLanguageAnalyzer(indexMapping, language)
+	if err != nil {
+		return nil, err
+	}
+
+	indexMapping.DefaultAnalyzer = name
+	return indexMapping, nil
+}
+
+// addLanguageAnalyzer adds a custom analyzer for the given language to the index mapping, if
+// needed, and returns the name of the analyzer to use.
+func addLanguageAnalyzer(indexMapping *mapping.IndexMappingImpl, language string) (string, error) {
+	language = analyzerLanguage(language)
+
+	if name, ok := languageAnalyzers[language]; ok {
+		return name, nil
+	}
+
+	filters := []string{lowercase.Name}
+	name := fallbackAnalyzerName
+	if stopFilters, ok := languageStopFilters[language]; ok {
+		filters = append(filters, stopFilters...)
+		name = "subsyncer_" + language
+	}
+
+	err := indexMapping.AddCustomAnalyzer(name, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": filters,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// analyzerLanguage converts the given language code, in any form accepted by ParseLanguage, into the
+// ISO 639-1 code used to select analyzers, ignoring any script or region, e.g. "pt-BR". Unknown
+// languages are returned in lower case, without subtags, and analyzed by the fallback analyzer.
+func analyzerLanguage(language string) string {
+	parsed, err := ParseLanguage(language)
+	if err != nil {
+		language = strings.ToLower(language)
+		if i := strings.IndexAny(language, "-_"); i >= 0 {
+			language = language[:i]
+		}
+		return language
+	}
+
+	return parsed.Code
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAnalyzerLanguage(t *testing.T) {
+	cases := []struct {
+		language string
+		expected string
+	}{
+		{"en", "en"},
+		{"eng", "en"},
+		{"FRE", "fr"},
+		{"pt-BR", "pt"},
+		{"zh_Hant", "zh"},
+		{"heb", "he"},
+		{"Hebrew", "he"},
+		{"klingon", "klingon"},
+	}
+
+	for _, c := range cases {
+		actual := analyzerLanguage(c.language)
+		if actual != c.expected {
+			t.Errorf("Expected analyzer language of '%s' to be '%s', got '%s'", c.language, c.expected, actual)
+		}
+	}
+}
+
+func TestIndexedSubtitleSearchLanguages(t *testing.T) {
+	cases := []struct {
+		language string
+		text     []string
+		query    string
+	}{
+		{"fr", []string{"Les enfants jouent", "dans le jardin."}, "L'enfant, dans les jardins"},
+		{"de", []string{"Die Kinder spielen", "im Garten."}, "Kinder spielten im Garten"},
+		{"spa", []string{"Los niños juegan", "en el jardín."}, "Los niños, en los jardines"},
+		{"zh", []string{"孩子们在花园里玩"}, "在花园里"},
+		{"heb", []string{"הילדים משחקים בגינה"}, "משחקים בגינה"},
+		{"bg", []string{"Децата играят в градината"}, "играят в градината"},
+	}
+
+	for _, c := range cases {
+		subtitle := &SubtitleFile{
+			Entries: []*SubtitleEntry{
+				{Index: 1, Text: c.text},
+				{Index: 2, Text: []string{"Lorem ipsum dolor sit amet"}},
+			},
+		}
+
+		indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: c.language})
+		if err != nil {
+			t.Fatalf("Expected no error to occur while indexing subtitle (%s), got error: %v", c.language, err)
+		}
+
+		resEntry, err := indexedSub.Search(c.query)
+		if err != nil {
+			t.Fatalf("Got error while searching (%s): %v", c.language, err)
+		}
+
+		if resEntry == nil || resEntry.Index != 1 {
+			t.Errorf("Expected entry 1 to be found (%s), got %v", c.language, resEntry)
+		}
+	}
+}
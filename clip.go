@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clip keeps only the entries within the time window [from, to), and rebases them so that the
+// window starts at zero. Entries partially within the window are trimmed to fit it.
+// The remaining entries are renumbered. A zero to leaves the window open, through the end of the file.
+func (f *SubtitleFile) Clip(from, to time.Duration) error {
+	if from < 0 || (to != 0 && to <= from) {
+		return fmt.Errorf("Invalid clip window: [%v, %v)", from, to)
+	}
+
+	if to == 0 {
+		to = math.MaxInt64
+	}
+
+	f.filter(func(entry *SubtitleEntry) bool {
+		return entry.End > from && entry.Start < to
+	})
+
+	for _, entry := range f.Entries {
+		if entry.Start < from {
+			entry.Start = from
+		}
+		if entry.End > to {
+			entry.End = to
+		}
+	}
+
+	return f.Shift(-from)
+}
+
+// DropMatching drops all entries whose text matches the given pattern, with entry lines
+// joined by a space. The remaining entries are renumbered.
+func (f *SubtitleFile) DropMatching(pattern *regexp.Regexp) {
+	f.filter(func(entry *SubtitleEntry) bool {
+		return !pattern.MatchString(strings.Join(entry.Text, " "))
+	})
+}
+
+// KeepRange keeps only the entries whose index is within the given range.
+// The remaining entries are renumbered.
+func (f *SubtitleFile) KeepRange(r IndexRange) {
+	f.filter(r.Contains)
+}
+
+// DropRange drops all entries whose index is within the given range.
+// The remaining entries are renumbered.
+func (f *SubtitleFile) DropRange(r IndexRange) {
+	f.filter(func(entry *SubtitleEntry) bool {
+		return !r.Contains(entry)
+	})
+}
+
+// filter keeps only the entries satisfying the given predicate, and renumbers them.
+func (f *SubtitleFile) filter(keep func(entry *SubtitleEntry) bool) {
+	entries := make([]*SubtitleEntry, 0, len(f.Entries))
+	for _, entry := range f.Entries {
+		if keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	f.Entries = entries
+	f.Renumber()
+}
+
+// IndexRange is an inclusive range of subtitle entry indices.
+type IndexRange struct {
+	First int
+	Last  int
+}
+
+// ParseIndexRange parses the given string as an index range, i.e. "first-last", "first-",
+// "-last" or a single index.
+func ParseIndexRange(s string) (IndexRange, error) {
+	r := IndexRange{First: 1, Last: int(^uint(0) >> 1)}
+
+	var err error
+	first, last := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		first, last = s[:i], s[i+1:]
+	}
+
+	if first = strings.TrimSpace(first); first != "" {
+		r.First, err = strconv.Atoi(first)
+		if err != nil {
+			return r, fmt.Errorf("Invalid index range: %s", s)
+		}
+	}
+
+	if last = strings.TrimSpace(last); last != "" {
+		r.Last, err = strconv.Atoi(last)
+		if err != nil {
+			return r, fmt.Errorf("Invalid index range: %s", s)
+		}
+	}
+
+	if r.First > r.Last {
+		return r, fmt.Errorf("Invalid index range: %s", s)
+	}
+
+	return r, nil
+}
+
+// Contains determines whether the index of the given entry is within the range.
+func (r IndexRange) Contains(entry *SubtitleEntry) bool {
+	return entry.Index >= r.First && entry.Index <= r.Last
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func newClipTestSubtitle() *SubtitleFile {
+	return &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("5s"), End: mustParseDuration("8s"), Text: []string{"Subtitles by SubsTeam"}},
+			{Index: 2, Start: mustParseDuration("10s"), End: mustParseDuration("12s"), Text: []string{"Where were you?"}},
+			{Index: 3, Start: mustParseDuration("14s"), End: mustParseDuration("16s"), Text: []string{"At home."}},
+			{Index: 4, Start: mustParseDuration("19s"), End: mustParseDuration("22s"), Text: []string{"Alone?"}},
+		},
+	}
+}
+
+func TestSubtitleFileClip(t *testing.T) {
+	subtitle := newClipTestSubtitle()
+
+	err := subtitle.Clip(mustParseDuration("11s"), mustParseDuration("20s"))
+	if err != nil {
+		t.Fatalf("Expected no error to occur while clipping subtitle, got error: %v", err)
+	}
+
+	if len(subtitle.Entries) != 3 {
+		t.Fatalf("Expected 3 entries after clip, got %d", len(subtitle.Entries))
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "0s", "1s", "Where were you?")
+	assertEntry(t, subtitle.Entries[1], 2, "3s", "5s", "At home.")
+	assertEntry(t, subtitle.Entries[2], 3, "8s", "9s", "Alone?")
+}
+
+func TestSubtitleFileClipOpenEnded(t *testing.T) {
+	subtitle := newClipTestSubtitle()
+
+	err := subtitle.Clip(mustParseDuration("13s"), 0)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while clipping subtitle, got error: %v", err)
+	}
+
+	if len(subtitle.Entries) != 2 {
+		t.Fatalf("Expected 2 entries after clip, got %d", len(subtitle.Entries))
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "1s", "3s", "At home.")
+	assertEntry(t, subtitle.Entries[1], 2, "6s", "9s", "Alone?")
+}
+
+func TestSubtitleFileClipInvalidWindow(t *testing.T) {
+	subtitle := newClipTestSubtitle()
+
+	err := subtitle.Clip(mustParseDuration("20s"), mustParseDuration("10s"))
+	if err == nil {
+		t.Fatalf("Expected an error to occur while clipping to an invalid window")
+	}
+}
+
+func TestSubtitleFileDropMatching(t *testing.T) {
+	subtitle := newClipTestSubtitle()
+
+	subtitle.DropMatching(regexp.MustCompile(`(?i)subtitles by`))
+
+	if len(subtitle.Entries) != 3 {
+		t.Fatalf("Expected 3 entries after filtering, got %d", len(subtitle.Entries))
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "10s", "12s", "Where were you?")
+}
+
+func TestSubtitleFileIndexRanges(t *testing.T) {
+	cases := []struct {
+		r        string
+		keep     []string
+		drop     []string
+		hasError bool
+	}{
+		{"2-3", []string{"Where were you?", "At home."}, []string{"Subtitles by SubsTeam", "Alone?"}, false},
+		{"3-", []string{"At home.", "Alone?"}, []string{"Subtitles by SubsTeam", "Where were you?"}, false},
+		{"-1", []string{"Subtitles by SubsTeam"}, []string{"Where were you?", "At home.", "Alone?"}, false},
+		{"4", []string{"Alone?"}, []string{"Subtitles by SubsTeam", "Where were you?", "At home."}, false},
+		{"3-2", nil, nil, true},
+		{"a-b", nil, nil, true},
+	}
+
+	for i, c := range cases {
+		r, err := ParseIndexRange(c.r)
+		if c.hasError {
+			if err == nil {
+				t.Errorf("Expected an error to occur while parsing index range (case %d)", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Got error while parsing index range (case %d): %v", i, err)
+		}
+
+		kept := newClipTestSubtitle()
+		kept.KeepRange(r)
+		assertTexts(t, kept, c.keep...)
+
+		dropped := newClipTestSubtitle()
+		dropped.DropRange(r)
+		assertTexts(t, dropped, c.drop...)
+	}
+}
+
+func assertTexts(t *testing.T, subtitle *SubtitleFile, texts ...string) {
+	if len(subtitle.Entries) != len(texts) {
+		t.Errorf("Expected %d entries, got %d", len(texts), len(subtitle.Entries))
+		return
+	}
+
+	for i, text := range texts {
+		assertEntry(t, subtitle.Entries[i], i+1,
+			subtitle.Entries[i].Start.String(), subtitle.Entries[i].End.String(), text)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// command is a standalone subsyncer command, invoked as "subsyncer <name> [flags] [file]".
+type command struct {
+	name        string
+	description string
+
+	// args describes the positional arguments of the command. If empty, the command takes
+	// a single optional subtitle file, read from the standard input if not given.
+	args string
+
+	// examples are example invocations of the command, shown by its usage.
+	examples []string
+
+	// flags registers the command specific flags on the given flag set.
+	flags func(fs *flag.FlagSet)
+
+	// run executes the command, given its parsed flag set.
+	run func(fs *flag.FlagSet) error
+}
+
+var (
+	commands = make(map[string]*command)
+)
+
+// registerCommand makes the given command available to the command line.
+func registerCommand(cmd *command) {
+	commands[cmd.name] = cmd
+}
+
+// execute parses the given command line arguments, and runs the command.
+func (cmd *command) execute(args []string) error {
+	fs := cmd.flagSet()
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(fs)
+}
+
+// flagSet creates the flag set of the command, printing its usage on errors.
+func (cmd *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		args := cmd.args
+		if args == "" {
+			args = "[file]"
+		}
+
+		fmt.Fprintf(os.Stderr, "Usage: subsyncer %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, args, cmd.description)
+		fs.PrintDefaults()
+
+		if len(cmd.examples) > 0 {
+			fmt.Fprintf(os.Stderr, "\nExamples:\n")
+			for _, example := range cmd.examples {
+				fmt.Fprintf(os.Stderr, "  %s\n", example)
+			}
+		}
+	}
+
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+
+	return fs
+}
+
+// commandNames returns the names of all registered commands, in alphabetical order.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// outputFlags holds the flags controlling how a command writes its resulting subtitle file.
+// The resulting subtitle file is written to the --output file, over the input file if --in-place,
+// or to the standard output otherwise.
+type outputFlags struct {
+	path          string
+	inPlace       bool
+	removeAds     bool
+	adPatterns    string
+	wrap          bool
+	maxLineLength int
+	maxLines      int
+
+	// format is the format of the resulting subtitle file. If nil, it's given by the extension of
+	// the output file, or otherwise is the format of the input subtitle file.
+	format *subtitleFormat
+
+	// input is the source of the subtitle file read by read, if any.
+	input *subtitleSource
+}
+
+// register registers the output flags on the given flag set.
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.path, "output", "", "Path to write the resulting subtitle file to (default: standard output)")
+	fs.BoolVar(&o.inPlace, "in-place", false, "Overwrite the input subtitle file with the resulting subtitle file")
+	fs.BoolVar(&o.removeAds, "remove-ads", false, "Remove advertisement and credit entries from the resulting subtitle file, and leave them out of matching")
+	fs.StringVar(&o.adPatterns, "ad-patterns", "", "Path to a file of additional advertisement patterns, one regular expression per line, used with --remove-ads")
+	fs.BoolVar(&o.wrap, "wrap", false, "Re-wrap the text of the resulting subtitle entries")
+	fs.IntVar(&o.maxLineLength, "max-line-length", defaultMaxLineLength, "Maximal line length when re-wrapping text")
+	fs.IntVar(&o.maxLines, "max-lines", defaultMaxLines, "Maximal number of lines per entry when re-wrapping text")
+}
+
+// read reads the input subtitle file at the given path, or from the standard input if the path is
+// empty or "-", checking that it can be written as specified by the output flags.
+func (o *outputFlags) read(path string) (*SubtitleFile, error) {
+	if o.inPlace {
+		if o.path != "" {
+			return nil, fmt.Errorf("The --in-place and --output flags can't be used together")
+		}
+		if path == "" || path == "-" {
+			return nil, fmt.Errorf("The --in-place flag requires an input subtitle file")
+		}
+	}
+
+	subtitle, source, err := readSubtitleFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	o.input = source
+	return subtitle, nil
+}
+
+// adDetector creates the AdDetector removing ads from the resulting subtitle file, which is also
+// used to leave ads out of matching, or returns nil if ads are kept.
+func (o *outputFlags) adDetector() (*AdDetector, error) {
+	if !o.removeAds {
+		if o.adPatterns != "" {
+			return nil, fmt.Errorf("The --ad-patterns flag requires --remove-ads")
+		}
+		return nil, nil
+	}
+
+	return NewAdDetector(o.adPatterns)
+}
+
+// write lays out the given subtitle file and writes it, as specified by the output flags.
+func (o *outputFlags) write(subtitle *SubtitleFile) error {
+	detector, err := o.adDetector()
+	if err != nil {
+		return err
+	}
+
+	if detector != nil {
+		for _, entry := range detector.Remove(subtitle) {
+			fmt.Fprintf(os.Stderr, "Removed advertisement entry %d: %s\n", entry.Index, strings.Join(entry.Text, " "))
+		}
+	}
+
+	if o.wrap {
+		subtitle.Wrap(o.maxLineLength, o.maxLines)
+	}
+
+	path := o.path
+	if o.inPlace && o.input != nil {
+		path = o.input.path
+	}
+
+	format := o.format
+	if format == nil {
+		format = subtitleFormatOfPath(path)
+	}
+	if format == nil && o.input != nil {
+		format = o.input.format
+	}
+	if format == nil {
+		format = srtFormat
+	}
+
+	return writeSubtitleFile(subtitle, path, format)
+}
+
+// translatorFlags holds the flags selecting and configuring the translation provider.
+type translatorFlags struct {
+	configPath      string
+	provider        string
+	config          ProviderConfig
+	cache           translationCacheFlags
+	maxRetries      int
+	maxUntranslated float64
+
+	// openCache is the translation cache opened for the translator, if any.
+	openCache *TranslationCache
+}
+
+// register registers the translator flags on the given flag set.
+func (t *translatorFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.configPath, "config", defaultConfigPath(), "Path to the config file, configuring the translation providers")
+	fs.StringVar(&t.provider, "translator", "", "Translation provider: "+strings.Join(translationProviderNames(), ", ")+" (default: by the config file, or "+defaultTranslationProvider+")")
+	fs.StringVar(&t.config.URL, "translator-url", "", "Base URL of the translation service (default: the public service of the provider)")
+	fs.StringVar(&t.config.AuthURL, "translator-auth-url", "", "URL of the token service of translation services using client credentials (default: the public service of the provider)")
+	fs.StringVar(&t.config.Region, "translator-region", "", "Region of the translation service resource, for services deployed per region")
+	fs.StringVar(&t.config.Key, "translator-key", "", "API key, or client ID, of the translation service")
+	fs.StringVar(&t.config.Secret, "translator-secret", "", "Client secret of the translation service, if required by the provider")
+	fs.StringVar(&t.config.Dictionary, "dictionary", "", "Path to a bilingual dictionary file for the dictionary translator: .tsv, FreeDict .tei or Wiktextract .jsonl")
+	fs.Float64Var(&t.config.RateLimit.RequestsPerSecond, "translator-rps", 0, "Maximal number of translation requests per second (default: by the config file, or unlimited; 0 can't lift a configured limit)")
+	fs.IntVar(&t.config.RateLimit.CharsPerMinute, "translator-chars-per-minute", 0, "Maximal number of characters translated per minute (default: by the config file, or unlimited; 0 can't lift a configured limit)")
+	fs.DurationVar(&t.config.Timeout, "translator-timeout", 0, "Time limit of each translation request, after which it's retried (default: by the config file, or "+defaultProviderTimeout.String()+")")
+	fs.IntVar(&t.maxRetries, "translator-retries", defaultMaxRetries, "Maximal number of retries of failed translation requests")
+	fs.Float64Var(&t.maxUntranslated, "max-untranslated", 0, "Fraction of entries, between 0 and 1, which may be left untranslated on failures")
+	t.cache.register(fs)
+}
+
+// translator creates the Translator selected by the translator flags, using the translation
+// cache unless disabled, or in use by another process. The cache must be closed using close
+// once done translating.
+func (t *translatorFlags) translator(options TranslateOptions) (Translator, error) {
+	if t.maxUntranslated < 0 || t.maxUntranslated > 1 {
+		return nil, fmt.Errorf("The fraction of untranslated entries must be between 0 and 1, got %v", t.maxUntranslated)
+	}
+
+	options.Retry.MaxRetries = t.maxRetries
+	if t.maxRetries == 0 {
+		options.Retry.MaxRetries = -1
+	}
+	options.MaxUntranslated = t.maxUntranslated
+
+	if t.cache.path != "" {
+		cache, err := t.cache.open()
+		switch {
+		case err == bolt.ErrTimeout:
+			// Another subsyncer process holds the cache, so translate without it rather than wait
+			fmt.Fprintf(os.Stderr, "Warning: the translation cache %s is in use by another process, translating without it\n", t.cache.path)
+		case err != nil:
+			return nil, err
+		default:
+			t.openCache = cache
+			options.Cache = cache
+		}
+	}
+
+	provider, config, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProviderTranslator(provider, config, options)
+}
+
+// detector creates a LanguageDetector using the translation provider selected by the translator flags.
+func (t *translatorFlags) detector() (LanguageDetector, error) {
+	provider, config, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProviderDetector(provider, config)
+}
+
+// resolve returns the selected translation provider and its config, as given by the flags, on top
+// of the environment variables and the config file.
+func (t *translatorFlags) resolve() (string, ProviderConfig, error) {
+	config, err := LoadConfig(t.configPath)
+	if err != nil {
+		return "", ProviderConfig{}, err
+	}
+
+	provider := t.provider
+	if provider == "" {
+		provider = config.Translator
+	}
+	if provider == "" {
+		provider = defaultTranslationProvider
+	}
+
+	return provider, config.Providers[provider].merge(t.config), nil
+}
+
+// close closes the translation cache opened for the translator, if any.
+func (t *translatorFlags) close() error {
+	if t.openCache == nil {
+		return nil
+	}
+
+	err := t.openCache.Close()
+	t.openCache = nil
+	return err
+}
+
+// translationCacheFlags holds the flags locating and configuring the translation cache.
+type translationCacheFlags struct {
+	path    string
+	options CacheOptions
+}
+
+// register registers the translation cache flags on the given flag set.
+func (c *translationCacheFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "translation-cache", defaultTranslationCachePath(), "Path to the translation cache file, or empty to disable caching")
+	fs.DurationVar(&c.options.TTL, "translation-cache-ttl", 0, "Duration cached translations are used for (default: forever)")
+	fs.IntVar(&c.options.MaxEntries, "translation-cache-max-entries", 0, "Maximal number of cached translations, beyond which the oldest are evicted (default: unlimited)")
+}
+
+// open opens the translation cache.
+func (c *translationCacheFlags) open() (*TranslationCache, error) {
+	if c.path == "" {
+		return nil, fmt.Errorf("No translation cache file specified")
+	}
+
+	return OpenTranslationCache(c.path, c.options)
+}
+
+// readSubtitle reads the subtitle file at the given path, or from the standard input if the
+// path is empty or "-", in any supported format and encoding.
+func readSubtitle(path string) (*SubtitleFile, error) {
+	subtitle, _, err := readSubtitleFile(path)
+	return subtitle, err
+}
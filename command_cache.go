@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func init() {
+	var (
+		cacheFlags    translationCacheFlags
+		indexCacheDir string
+	)
+
+	registerCommand(&command{
+		name:        "cache",
+		description: "Show statistics of the translation cache and of the persistent indexes (\"cache stats\"), or clear them (\"cache clear\")",
+		flags: func(fs *flag.FlagSet) {
+			cacheFlags.register(fs)
+			fs.StringVar(&indexCacheDir, "index-cache-dir", defaultIndexCacheDir(), "Directory of the persistent reference subtitle indexes, or empty to leave them out")
+		},
+		run: func(fs *flag.FlagSet) error {
+			action := fs.Arg(0)
+			if action != "stats" && action != "clear" {
+				return fmt.Errorf("Expected a cache action, \"stats\" or \"clear\", got \"%s\"", action)
+			}
+
+			cache, err := cacheFlags.open()
+			if err != nil {
+				return err
+			}
+			defer cache.Close()
+
+			if action == "clear" {
+				err = cache.Clear()
+				if err != nil || indexCacheDir == "" {
+					return err
+				}
+				return ClearIndexCache(indexCacheDir)
+			}
+
+			stats, err := cache.Stats()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stdout, "Cache file:       %s\n", cacheFlags.path)
+			fmt.Fprintf(os.Stdout, "Size:             %d bytes\n", stats.Size)
+			fmt.Fprintf(os.Stdout, "Entries:          %d\n", stats.Entries)
+			if cacheFlags.options.TTL > 0 {
+				fmt.Fprintf(os.Stdout, "Expired entries:  %d\n", stats.ExpiredEntries)
+			}
+			if stats.Entries > 0 {
+				fmt.Fprintf(os.Stdout, "Oldest entry:     %s\n", stats.Oldest.Format(time.RFC3339))
+				fmt.Fprintf(os.Stdout, "Newest entry:     %s\n", stats.Newest.Format(time.RFC3339))
+			}
+			for _, pair := range stats.pairNames() {
+				fmt.Fprintf(os.Stdout, "  %-24s %d\n", pair, stats.Pairs[pair])
+			}
+
+			if indexCacheDir != "" {
+				indexes, size, err := IndexCacheStats(indexCacheDir)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(os.Stdout, "Index directory:  %s\n", indexCacheDir)
+				fmt.Fprintf(os.Stdout, "Indexes:          %d (%d bytes)\n", indexes, size)
+			}
+
+			return nil
+		},
+	})
+}
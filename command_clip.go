@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+func init() {
+	var (
+		output outputFlags
+		from   time.Duration
+		to     time.Duration
+	)
+
+	registerCommand(&command{
+		name:        "clip",
+		description: "Extract the entries within a time window, rebased to start at zero",
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			fs.DurationVar(&from, "from", 0, "Start of the time window (inclusive)")
+			fs.DurationVar(&to, "to", 0, "End of the time window (exclusive), or 0 for the end of the file")
+		},
+		run: func(fs *flag.FlagSet) error {
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			err = subtitle.Clip(from, to)
+			if err != nil {
+				return err
+			}
+
+			return output.write(subtitle)
+		},
+	})
+}
+
+func init() {
+	var (
+		output       outputFlags
+		dropMatching string
+		keepRange    string
+		dropRange    string
+	)
+
+	registerCommand(&command{
+		name:        "filter",
+		description: "Drop subtitle entries by text pattern or index range",
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			fs.StringVar(&dropMatching, "drop-matching", "", "Drop entries whose text matches the given regular expression")
+			fs.StringVar(&keepRange, "keep", "", "Keep only entries within the given index range, e.g. \"10-20\"")
+			fs.StringVar(&dropRange, "drop", "", "Drop entries within the given index range, e.g. \"1-3\"")
+		},
+		run: func(fs *flag.FlagSet) error {
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			// Index ranges refer to the original numbering, so apply them together first.
+			var keep, drop *IndexRange
+			if keepRange != "" {
+				r, err := ParseIndexRange(keepRange)
+				if err != nil {
+					return err
+				}
+				keep = &r
+			}
+			if dropRange != "" {
+				r, err := ParseIndexRange(dropRange)
+				if err != nil {
+					return err
+				}
+				drop = &r
+			}
+
+			subtitle.filter(func(entry *SubtitleEntry) bool {
+				return (keep == nil || keep.Contains(entry)) && (drop == nil || !drop.Contains(entry))
+			})
+
+			if dropMatching != "" {
+				pattern, err := regexp.Compile(dropMatching)
+				if err != nil {
+					return fmt.Errorf("Invalid pattern: %v", err)
+				}
+				subtitle.DropMatching(pattern)
+			}
+
+			return output.write(subtitle)
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func init() {
+	var translation translatorFlags
+
+	registerCommand(&command{
+		name:        "config",
+		description: "Show the effective configuration (\"config show\"), merging the config file, environment variables and flags, with secrets redacted",
+		flags: func(fs *flag.FlagSet) {
+			translation.register(fs)
+		},
+		run: func(fs *flag.FlagSet) error {
+			if action := fs.Arg(0); action != "show" {
+				return fmt.Errorf("Expected a config action, \"show\", got \"%s\"", action)
+			}
+
+			config, err := LoadConfig(translation.configPath)
+			if err != nil {
+				return err
+			}
+
+			provider, providerConfig, err := translation.resolve()
+			if err != nil {
+				return err
+			}
+
+			config.Translator = provider
+			config.Providers[provider] = providerConfig
+
+			status := ""
+			if _, err := os.Stat(translation.configPath); os.IsNotExist(err) {
+				status = " (not found)"
+			}
+			fmt.Fprintf(os.Stdout, "# Config file: %s%s\n", translation.configPath, status)
+
+			return config.Write(os.Stdout)
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	var (
+		output outputFlags
+		to     string
+	)
+
+	registerCommand(&command{
+		name:        "convert",
+		description: "Convert a subtitle file into another format, and into UTF-8",
+		examples: []string{
+			"subsyncer convert --to=vtt MyMovie.srt > MyMovie.vtt",
+			"subsyncer convert --output=MyMovie.srt MyMovie.vtt",
+		},
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			fs.StringVar(&to, "to", "", "Format to convert the subtitle file into: "+strings.Join(subtitleFormatNames(), ", ")+" (default: by the output file extension)")
+		},
+		run: func(fs *flag.FlagSet) error {
+			if to != "" {
+				format, err := lookupSubtitleFormat(to)
+				if err != nil {
+					return err
+				}
+				output.format = format
+			} else if subtitleFormatOfPath(output.path) == nil {
+				return fmt.Errorf("No format to convert into specified (--to)")
+			}
+
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			return output.write(subtitle)
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:        "info",
+		description: "Describe subtitle files: their format, encoding, language, number of entries and duration",
+		args:        "[file...]",
+		examples: []string{
+			"subsyncer info MyMovie.srt",
+			"subsyncer info *.srt *.vtt",
+		},
+		run: func(fs *flag.FlagSet) error {
+			paths := fs.Args()
+			if len(paths) == 0 {
+				paths = []string{""}
+			}
+
+			for i, path := range paths {
+				if i > 0 {
+					fmt.Println()
+				}
+
+				err := printSubtitleInfo(path)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+}
+
+// printSubtitleInfo describes the subtitle file at the given path, or read from the standard input
+// if the path is empty or "-".
+func printSubtitleInfo(path string) error {
+	subtitle, source, err := readSubtitleFile(path)
+	if err != nil {
+		return err
+	}
+
+	name := source.path
+	if name == "" {
+		name = "(standard input)"
+	}
+
+	var duration time.Duration
+	for _, entry := range subtitle.Entries {
+		if entry.End > duration {
+			duration = entry.End
+		}
+	}
+
+	language := "unknown"
+	if tag, ok := LanguageFromFilename(source.path); ok {
+		language = fmt.Sprintf("%s (%s), by the file name", tag.Name(), tag.Tag())
+	} else if guess, err := DetectSubtitleLanguage(subtitle); err == nil {
+		language = fmt.Sprintf("%s, detected", guess)
+	}
+
+	fmt.Printf("File:      %s\n", name)
+	fmt.Printf("Format:    %s (%s)\n", source.format.description, source.format.name)
+	fmt.Printf("Encoding:  %s\n", source.encoding)
+	fmt.Printf("Language:  %s\n", language)
+	fmt.Printf("Entries:   %d\n", len(subtitle.Entries))
+	fmt.Printf("Duration:  %s\n", timestampString(duration))
+
+	return nil
+}
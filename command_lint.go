@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func init() {
+	var options LintOptions
+
+	registerCommand(&command{
+		name:        "lint",
+		description: "Check subtitle files for timing, numbering and layout problems",
+		args:        "[file...]",
+		examples: []string{
+			"subsyncer lint MyMovie.srt",
+			"subsyncer lint --max-chars-per-second=0 --max-line-length=37 *.srt",
+		},
+		flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&options.MaxLineLength, "max-line-length", defaultMaxLineLength, "Maximal line length, or 0 not to check line lengths")
+			fs.IntVar(&options.MaxLines, "max-lines", defaultMaxLines, "Maximal number of lines per entry, or 0 not to check it")
+			fs.DurationVar(&options.MinDuration, "min-duration", defaultLintMinDuration, "Minimal duration of an entry, or 0 not to check it")
+			fs.DurationVar(&options.MaxDuration, "max-duration", defaultLintMaxDuration, "Maximal duration of an entry, or 0 not to check it")
+			fs.Float64Var(&options.MaxCharsPerSecond, "max-chars-per-second", defaultLintMaxCharsPerSecond, "Maximal reading speed of an entry, or 0 not to check it")
+		},
+		run: func(fs *flag.FlagSet) error {
+			paths := fs.Args()
+			if len(paths) == 0 {
+				paths = []string{""}
+			}
+
+			count := 0
+			for _, path := range paths {
+				subtitle, err := readSubtitle(path)
+				if err != nil {
+					return err
+				}
+
+				name := path
+				if name == "" || name == "-" {
+					name = "(standard input)"
+				}
+
+				for _, issue := range Lint(subtitle, options) {
+					fmt.Fprintf(os.Stdout, "%s:%s\n", name, issue)
+					count++
+				}
+			}
+
+			if count > 0 {
+				return fmt.Errorf("Found %d issues", count)
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+const (
+	defaultMergeMaxGap    = 500 * time.Millisecond
+	defaultMergeMaxLength = 84
+	defaultSplitMaxLength = 42
+)
+
+func init() {
+	var (
+		output    outputFlags
+		maxGap    time.Duration
+		maxLength int
+	)
+
+	registerCommand(&command{
+		name:        "merge",
+		description: "Merge consecutive subtitle entries which are close enough in time",
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			fs.DurationVar(&maxGap, "max-gap", defaultMergeMaxGap, "Maximal gap between merged entries")
+			fs.IntVar(&maxLength, "max-length", defaultMergeMaxLength, "Maximal number of characters in a merged entry")
+		},
+		run: func(fs *flag.FlagSet) error {
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			subtitle.Merge(maxGap, maxLength)
+			return output.write(subtitle)
+		},
+	})
+}
+
+func init() {
+	var (
+		output    outputFlags
+		maxLength int
+	)
+
+	registerCommand(&command{
+		name:        "split",
+		description: "Split long subtitle entries at dialogue turns and sentence boundaries",
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			fs.IntVar(&maxLength, "max-length", defaultSplitMaxLength, "Maximal number of characters in an entry which is not split")
+		},
+		run: func(fs *flag.FlagSet) error {
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			subtitle.Split(maxLength)
+			return output.write(subtitle)
+		},
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+func init() {
+	var (
+		output outputFlags
+		by     time.Duration
+	)
+
+	registerCommand(&command{
+		name:        "shift",
+		description: "Move all subtitle entries earlier or later by a constant duration",
+		examples: []string{
+			"subsyncer shift --by=-2.5s MyMovie.srt > MyMovie.synced.srt",
+			"subsyncer shift --by=1m30s --in-place MyMovie.srt",
+		},
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			fs.DurationVar(&by, "by", 0, "Duration to move the entries by, negative to move them earlier, e.g. \"-2.5s\"")
+		},
+		run: func(fs *flag.FlagSet) error {
+			if by == 0 {
+				return fmt.Errorf("No duration to shift by specified (--by)")
+			}
+
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			err = subtitle.Shift(by)
+			if err != nil {
+				return err
+			}
+
+			return output.write(subtitle)
+		},
+	})
+}
+
+func init() {
+	var (
+		output  outputFlags
+		factor  float64
+		fromFPS float64
+		toFPS   float64
+	)
+
+	registerCommand(&command{
+		name:        "scale",
+		description: "Stretch or compress the timing of all subtitle entries, e.g. between video frame rates",
+		examples: []string{
+			"subsyncer scale --from-fps=23.976 --to-fps=25 MyMovie.srt > MyMovie.25fps.srt",
+			"subsyncer scale --factor=1.001 --in-place MyMovie.srt",
+		},
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			fs.Float64Var(&factor, "factor", 0, "Factor to multiply the timestamps of the entries by")
+			fs.Float64Var(&fromFPS, "from-fps", 0, "Frame rate of the video the subtitle file is synchronized to")
+			fs.Float64Var(&toFPS, "to-fps", 0, "Frame rate of the video to synchronize the subtitle file to")
+		},
+		run: func(fs *flag.FlagSet) error {
+			switch {
+			case factor != 0 && (fromFPS != 0 || toFPS != 0):
+				return fmt.Errorf("Either a factor (--factor) or frame rates (--from-fps and --to-fps) may be specified, not both")
+			case factor == 0 && (fromFPS <= 0 || toFPS <= 0):
+				return fmt.Errorf("No scale factor (--factor) or positive frame rates (--from-fps and --to-fps) specified")
+			case factor == 0:
+				// Frames are shown for longer at lower frame rates
+				factor = fromFPS / toFPS
+			}
+
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			err = subtitle.Scale(float32(factor))
+			if err != nil {
+				return err
+			}
+
+			return output.write(subtitle)
+		},
+	})
+}
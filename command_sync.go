@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// syncFlags holds the flags of the sync command, which are also accepted by subsyncer when
+// invoked without a command.
+type syncFlags struct {
+	inputFile     string
+	inputLanguage string
+
+	referenceFile     string
+	referenceLanguage string
+
+	pivotLanguage string
+
+	stripSDH       bool
+	indexBackend   string
+	similarity     string
+	embeddingsFile string
+	indexCacheDir  string
+	fuzziness      int
+
+	translator          translatorFlags
+	crossCheckLanguages bool
+
+	output outputFlags
+}
+
+func init() {
+	var sync syncFlags
+
+	registerCommand(&command{
+		name:        "sync",
+		description: "Synchronize a subtitle file to a reference subtitle file, possibly in another language",
+		args:        "[input file]",
+		examples: []string{
+			"subsyncer sync --ref-file=MyMovie.en.srt MyMovie.he.srt",
+			"subsyncer sync --input-lang=he --ref-file=MyMovie.en.srt --ref-lang=en MyMovie.srt",
+			"subsyncer sync --pivot-lang=en --ref-file=MyMovie.fr.srt MyMovie.he.srt",
+		},
+		flags: sync.register,
+		run: func(fs *flag.FlagSet) error {
+			if sync.inputFile == "" {
+				sync.inputFile = fs.Arg(0)
+			}
+
+			return sync.run()
+		},
+	})
+}
+
+// register registers the sync flags on the given flag set.
+func (s *syncFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.inputFile, "input-file", "", "Path to subtitle file to synchronize")
+	fs.StringVar(&s.inputLanguage, "input-lang", "", "Language of subtitle file to synchronize (default: detected)")
+	fs.StringVar(&s.referenceFile, "ref-file", "", "Path to reference subtitle file")
+	fs.StringVar(&s.referenceLanguage, "ref-lang", "", "Langauge of reference subtitle file (default: detected)")
+	fs.StringVar(&s.pivotLanguage, "pivot-lang", "", "Language to translate both subtitle files into before matching, e.g. \"en\" (default: translate the input subtitle file into the reference language)")
+	fs.StringVar(&s.indexBackend, "index-backend", "", "Reference subtitle index backend: \"bleve\", \"ngram\" or \"embedding\" (default: by reference language)")
+	fs.StringVar(&s.similarity, "similarity", "", "Similarity measure of the ngram index backend: \"cosine\", \"jaccard\" or \"edit\"")
+	fs.StringVar(&s.embeddingsFile, "embeddings", "", "Path to a word embeddings file, in word2vec/fastText text format, for the embedding index backend")
+	fs.StringVar(&s.indexCacheDir, "index-cache-dir", "", "Directory to persist reference subtitle indexes in, for reuse across runs, e.g. "+defaultIndexCacheDir()+", as managed by the cache command")
+	fs.IntVar(&s.fuzziness, "fuzziness", 0, "Maximal edit distance between matched terms of the input and reference subtitles")
+	fs.BoolVar(&s.stripSDH, "strip-sdh", false, "Remove hearing-impaired annotations from the synchronized subtitle file")
+	s.translator.register(fs)
+	fs.BoolVar(&s.crossCheckLanguages, "cross-check-lang", false, "Cross-check the detected languages of the subtitle files using the translation service")
+	s.output.register(fs)
+}
+
+// run synchronizes the input subtitle file to the reference subtitle file, by shifting its entries
+// by the offset estimated by matching them in the indexed reference subtitle file, and writes it.
+func (s *syncFlags) run() error {
+	if s.referenceFile == "" {
+		return fmt.Errorf("No reference subtitle file specified (--ref-file)")
+	}
+
+	input, err := s.output.read(s.inputFile)
+	if err != nil {
+		return err
+	}
+
+	if s.stripSDH {
+		input.StripSDH()
+	}
+
+	reference, err := readSubtitle(s.referenceFile)
+	if err != nil {
+		return err
+	}
+
+	err = s.resolveLanguages(input, reference)
+	if err != nil {
+		return err
+	}
+
+	defer s.translator.close()
+	matching, err := translateForMatching(input, reference, s.inputLanguage, s.referenceLanguage, s.pivotLanguage, func() (Translator, error) {
+		return s.translator.translator(TranslateOptions{})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, report := range []*TranslationReport{matching.InputReport, matching.ReferenceReport} {
+		if report != nil {
+			fmt.Fprintln(os.Stderr, report)
+		}
+	}
+
+	// Entries are matched once both subtitle files are segmented alike
+	index, err := s.index(matching.Reference.Resegment(), matching.Language)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	offset, err := EstimateOffset(matching.Input.Resegment(), index, 0)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Shifting the input subtitle file by %v\n", offset)
+	err = input.Shift(offset)
+	if err != nil {
+		return err
+	}
+
+	return s.output.write(input)
+}
+
+// index indexes the given reference subtitle file, in the given language, as specified by the flags.
+func (s *syncFlags) index(reference *SubtitleFile, language string) (IndexedSubtitle, error) {
+	ads, err := s.output.adDetector()
+	if err != nil {
+		return nil, err
+	}
+
+	options := IndexOptions{
+		Language:   language,
+		Backend:    s.indexBackend,
+		Fuzziness:  s.fuzziness,
+		Similarity: s.similarity,
+		CacheDir:   s.indexCacheDir,
+		Source:     s.referenceFile,
+		Ads:        ads,
+	}
+
+	if s.embeddingsFile != "" {
+		options.Embeddings, err = LoadWordEmbeddings(s.embeddingsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewIndexedSubtitle(reference, options)
+}
+
+// resolveLanguages detects the languages of the given input and reference subtitle files, if given
+// neither by flags nor by the language tags of their file names, and warns about given languages
+// which disagree with the detected ones.
+func (s *syncFlags) resolveLanguages(input, reference *SubtitleFile) error {
+	if s.pivotLanguage != "" {
+		if _, err := ParseLanguage(s.pivotLanguage); err != nil {
+			return err
+		}
+	}
+
+	var detector LanguageDetector
+	if s.crossCheckLanguages {
+		var err error
+		detector, err = s.translator.detector()
+		if err != nil {
+			return err
+		}
+	}
+
+	files := []struct {
+		name     string
+		path     string
+		subtitle *SubtitleFile
+		language *string
+	}{
+		{"the input subtitle file", s.inputFile, input, &s.inputLanguage},
+		{"the reference subtitle file", s.referenceFile, reference, &s.referenceLanguage},
+	}
+
+	for _, file := range files {
+		if *file.language == "" {
+			if language, ok := LanguageFromFilename(file.path); ok {
+				*file.language = language.Tag()
+			}
+		} else if _, err := ParseLanguage(*file.language); err != nil {
+			return err
+		}
+
+		var err error
+		*file.language, err = resolveSubtitleLanguage(file.name, file.subtitle, *file.language, detector, os.Stderr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
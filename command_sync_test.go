@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subsyncer-sync")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The input subtitle file is delayed by a known offset, and misses some entries
+	reference := newBenchmarkSubtitle(60)
+	input := &SubtitleFile{}
+	var expected []*SubtitleEntry
+	for i, entry := range reference.Entries {
+		if i%10 == 5 {
+			continue
+		}
+
+		shifted := *entry
+		shifted.Start += 12340 * time.Millisecond
+		shifted.End += 12340 * time.Millisecond
+		input.Entries = append(input.Entries, &shifted)
+		expected = append(expected, entry)
+	}
+	input.Renumber()
+
+	referencePath := filepath.Join(dir, "movie.en.srt")
+	inputPath := filepath.Join(dir, "movie.input.srt")
+	outputPath := filepath.Join(dir, "movie.synced.srt")
+	for path, subtitle := range map[string]*SubtitleFile{referencePath: reference, inputPath: input} {
+		err = writeSubtitleFile(subtitle, path, subtitleFormatOfPath(path))
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	err = commands["sync"].execute([]string{
+		"--input-lang=en", "--ref-lang=en", "--ref-file=" + referencePath,
+		"--translation-cache=", "--output=" + outputPath, inputPath,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while synchronizing, got error: %v", err)
+	}
+
+	synced, err := readSubtitle(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read the synchronized subtitle file: %v", err)
+	}
+
+	if len(synced.Entries) != len(input.Entries) {
+		t.Fatalf("Expected %d synchronized entries, got %d", len(input.Entries), len(synced.Entries))
+	}
+
+	// The synchronized entries are restored to the timing of the reference subtitle file
+	const tolerance = 100 * time.Millisecond
+	for i, entry := range synced.Entries {
+		if diff := entry.Start - expected[i].Start; diff < -tolerance || diff > tolerance {
+			t.Errorf("Expected entry %d to start at %v, got %v", entry.Index, expected[i].Start, entry.Start)
+		}
+		if diff := entry.End - expected[i].End; diff < -tolerance || diff > tolerance {
+			t.Errorf("Expected entry %d to end at %v, got %v", entry.Index, expected[i].End, entry.End)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func init() {
+	var (
+		output      outputFlags
+		translation translatorFlags
+		from        string
+		to          string
+		bilingual   bool
+	)
+
+	registerCommand(&command{
+		name:        "translate",
+		description: "Machine translate a subtitle file, keeping its timing and styling",
+		flags: func(fs *flag.FlagSet) {
+			output.register(fs)
+			translation.register(fs)
+			fs.StringVar(&from, "from", "", "Language of the subtitle file (default: by the file name, or detected)")
+			fs.StringVar(&to, "to", "", "Language to translate the subtitle file into")
+			fs.BoolVar(&bilingual, "bilingual", false, "Keep the original lines of each entry above the translated lines")
+		},
+		run: func(fs *flag.FlagSet) error {
+			if to == "" {
+				return fmt.Errorf("No language to translate into specified (--to)")
+			}
+
+			subtitle, err := output.read(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+
+			if from == "" {
+				if language, ok := LanguageFromFilename(fs.Arg(0)); ok {
+					from = language.Tag()
+				}
+			}
+			from, err = resolveSubtitleLanguage("the subtitle file", subtitle, from, nil, os.Stderr)
+			if err != nil {
+				return err
+			}
+
+			if sameLanguage(from, to) {
+				return fmt.Errorf("The subtitle file is already in %s", to)
+			}
+
+			translator, err := translation.translator(TranslateOptions{KeepOriginal: bilingual})
+			if err != nil {
+				return err
+			}
+			defer translation.close()
+
+			tSubtitle, report, err := translator.Translate(subtitle, from, to)
+			if report != nil {
+				fmt.Fprintln(os.Stderr, report)
+			}
+			if err != nil {
+				return err
+			}
+
+			if bilingual && output.wrap {
+				// Only the translated lines, following the original lines of each entry, are re-wrapped
+				for i, tEntry := range tSubtitle.Entries {
+					original := len(subtitle.Entries[i].Text)
+					if len(tEntry.Text) > original {
+						tEntry.Text = append(tEntry.Text[:original], wrapLines(tEntry.Text[original:], output.maxLineLength, output.maxLines)...)
+					}
+				}
+				output.wrap = false
+			}
+
+			return output.write(tSubtitle)
+		},
+	})
+}
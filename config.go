@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// configPathEnv is the environment variable overriding the path of the config file.
+	configPathEnv = "SUBSYNCER_CONFIG"
+
+	// envPrefix prefixes the environment variables configuring subsyncer, e.g. SUBSYNCER_DEEPL_KEY.
+	envPrefix = "SUBSYNCER_"
+)
+
+// Config is the configuration of subsyncer, as given by its config file and environment variables.
+//
+// The config file is a TOML file, located by default at $XDG_CONFIG_HOME/subsyncer/config.toml,
+// holding the default translation provider, and a table of settings per provider:
+//
+//	translator = "deepl"
+//
+//	[providers.deepl]
+//	url = "https://api-free.deepl.com"
+//	key = "..."
+//	chars_per_minute = 100000
+//	timeout = "1m"
+//
+// Environment variables override the config file: SUBSYNCER_TRANSLATOR selects the translation
+// provider, and SUBSYNCER_<PROVIDER>_<SETTING> variables, e.g. SUBSYNCER_DEEPL_KEY, override
+// provider settings. Command line flags override both.
+type Config struct {
+	// Translator is the name of the default translation provider.
+	Translator string
+
+	// Providers holds the settings of each translation provider, by name.
+	Providers map[string]ProviderConfig
+}
+
+// providerSettings are the settings of a translation provider, by their config file key.
+// The environment variable of each setting is its upper case key.
+var providerSettings = map[string]func(config *ProviderConfig, value string) error{
+	"url":        func(config *ProviderConfig, value string) error { config.URL = value; return nil },
+	"auth_url":   func(config *ProviderConfig, value string) error { config.AuthURL = value; return nil },
+	"region":     func(config *ProviderConfig, value string) error { config.Region = value; return nil },
+	"key":        func(config *ProviderConfig, value string) error { config.Key = value; return nil },
+	"secret":     func(config *ProviderConfig, value string) error { config.Secret = value; return nil },
+	"dictionary": func(config *ProviderConfig, value string) error { config.Dictionary = value; return nil },
+	"requests_per_second": func(config *ProviderConfig, value string) error {
+		rps, err := strconv.ParseFloat(value, 64)
+		config.RateLimit.RequestsPerSecond = rps
+		return err
+	},
+	"chars_per_minute": func(config *ProviderConfig, value string) error {
+		cpm, err := strconv.Atoi(value)
+		config.RateLimit.CharsPerMinute = cpm
+		return err
+	},
+	"timeout": func(config *ProviderConfig, value string) error {
+		timeout, err := time.ParseDuration(value)
+		config.Timeout = timeout
+		return err
+	},
+}
+
+// defaultConfigPath returns the path of the config file: the path given by the SUBSYNCER_CONFIG
+// environment variable, or config.toml in the subsyncer directory of the user config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv(configPathEnv); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "subsyncer", "config.toml")
+}
+
+// LoadConfig loads the config file at the given path, if any, and applies the environment
+// variables on top of it. A missing config file results in a config given by the environment alone.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{
+		Providers: make(map[string]ProviderConfig),
+	}
+
+	if path != "" {
+		file, err := os.Open(path)
+		switch {
+		case err == nil:
+			defer file.Close()
+			config, err = ReadConfig(file)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read config file %s: %v", path, err)
+			}
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+
+	err := config.applyEnv(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// ReadConfig reads a config file from the given stream. Only the subset of TOML used by config
+// files is supported: tables, and key/value pairs of strings, numbers and booleans.
+func ReadConfig(reader io.Reader) (*Config, error) {
+	config := &Config{
+		Providers: make(map[string]ProviderConfig),
+	}
+
+	scanner := bufio.NewScanner(reader)
+	table := ""
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(stripConfigComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("Invalid table header on line %d: %s", lineNumber, line)
+			}
+
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			if table != "providers" && !strings.HasPrefix(table, "providers.") {
+				return nil, fmt.Errorf("Unknown table on line %d: %s", lineNumber, table)
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("Expected a key/value pair on line %d: %s", lineNumber, line)
+		}
+
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"`)
+		value, err := parseConfigValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value of %s on line %d: %v", key, lineNumber, err)
+		}
+
+		err = config.set(table, key, value)
+		if err != nil {
+			return nil, fmt.Errorf("%v (line %d)", err, lineNumber)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// set sets the given key of the given table to the given value.
+func (c *Config) set(table, key, value string) error {
+	if table == "" {
+		if key != "translator" {
+			return fmt.Errorf("Unknown setting: %s", key)
+		}
+		c.Translator = value
+		return nil
+	}
+
+	provider := strings.TrimPrefix(table, "providers.")
+	if provider == table || provider == "" {
+		return fmt.Errorf("Settings of table [%s] must be given in a [providers.<name>] table", table)
+	}
+
+	setting, ok := providerSettings[key]
+	if !ok {
+		return fmt.Errorf("Unknown setting of provider %s: %s", provider, key)
+	}
+
+	config := c.Providers[provider]
+	err := setting(&config, value)
+	if err != nil {
+		return fmt.Errorf("Invalid value of %s.%s: %s", provider, key, value)
+	}
+	c.Providers[provider] = config
+
+	return nil
+}
+
+// applyEnv applies the environment variables, given by getenv, on top of the config.
+func (c *Config) applyEnv(getenv func(key string) string) error {
+	if translator := getenv(envPrefix + "TRANSLATOR"); translator != "" {
+		c.Translator = translator
+	}
+
+	for _, provider := range translationProviderNames() {
+		for key := range providerSettings {
+			env := envPrefix + strings.ToUpper(provider+"_"+key)
+			if value := getenv(env); value != "" {
+				err := c.set("providers."+provider, key, value)
+				if err != nil {
+					return fmt.Errorf("Invalid environment variable %s: %v", env, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// providerNames returns the names of the configured providers, in alphabetical order.
+func (c *Config) providerNames() []string {
+	names := make([]string, 0, len(c.Providers))
+	for name := range c.Providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Write writes the config in the config file format, with secrets redacted.
+func (c *Config) Write(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	if c.Translator != "" {
+		fmt.Fprintf(w, "translator = %s\n", strconv.Quote(c.Translator))
+	}
+
+	for _, name := range c.providerNames() {
+		config := c.Providers[name]
+		fmt.Fprintf(w, "\n[providers.%s]\n", name)
+		for _, setting := range []struct {
+			key   string
+			value string
+		}{
+			{"url", config.URL},
+			{"auth_url", config.AuthURL},
+			{"region", config.Region},
+			{"key", redactSecret(config.Key)},
+			{"secret", redactSecret(config.Secret)},
+			{"dictionary", config.Dictionary},
+		} {
+			if setting.value != "" {
+				fmt.Fprintf(w, "%s = %s\n", setting.key, strconv.Quote(setting.value))
+			}
+		}
+		if config.RateLimit.RequestsPerSecond > 0 {
+			fmt.Fprintf(w, "requests_per_second = %v\n", config.RateLimit.RequestsPerSecond)
+		}
+		if config.RateLimit.CharsPerMinute > 0 {
+			fmt.Fprintf(w, "chars_per_minute = %d\n", config.RateLimit.CharsPerMinute)
+		}
+		if config.Timeout > 0 {
+			fmt.Fprintf(w, "timeout = %s\n", strconv.Quote(config.Timeout.String()))
+		}
+	}
+
+	return w.Flush()
+}
+
+// parseConfigValue parses a TOML string, number or boolean value, returning it as a string.
+func parseConfigValue(value string) (string, error) {
+	switch {
+	case value == "":
+		return "", fmt.Errorf("Missing value")
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		// Literal strings have no escapes
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("Unterminated string: %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	case value == "true" || value == "false":
+		return value, nil
+	default:
+		number := strings.Replace(value, "_", "", -1)
+		if _, err := strconv.ParseFloat(number, 64); err != nil {
+			return "", fmt.Errorf("Unsupported value: %s", value)
+		}
+		return number, nil
+	}
+}
+
+// stripConfigComment removes the comment of the given config file line, if any.
+func stripConfigComment(line string) string {
+	quote := rune(0)
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote == '"':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// redactSecret hides the given secret, showing only its last characters if long enough to
+// tell secrets apart without revealing them.
+func redactSecret(secret string) string {
+	switch {
+	case secret == "":
+		return ""
+	case len(secret) < 16:
+		return "********"
+	default:
+		return "********" + secret[len(secret)-4:]
+	}
+}
+
+// String describes the provider config, with secrets redacted, so that it may be logged.
+func (c ProviderConfig) String() string {
+	return fmt.Sprintf("{URL:%s AuthURL:%s Region:%s Key:%s Secret:%s Dictionary:%s RateLimit:%+v Timeout:%v}",
+		c.URL, c.AuthURL, c.Region, redactSecret(c.Key), redactSecret(c.Secret), c.Dictionary, c.RateLimit, c.Timeout)
+}
+
+// merge returns the config, overridden by the non-zero settings of the given config. As zero
+// settings are ignored, rate limits can't be lifted by the given config.
+func (c ProviderConfig) merge(override ProviderConfig) ProviderConfig {
+	if override.URL != "" {
+		c.URL = override.URL
+	}
+	if override.AuthURL != "" {
+		c.AuthURL = override.AuthURL
+	}
+	if override.Region != "" {
+		c.Region = override.Region
+	}
+	if override.Key != "" {
+		c.Key = override.Key
+	}
+	if override.Secret != "" {
+		c.Secret = override.Secret
+	}
+	if override.Dictionary != "" {
+		c.Dictionary = override.Dictionary
+	}
+	if override.RateLimit.RequestsPerSecond > 0 {
+		c.RateLimit.RequestsPerSecond = override.RateLimit.RequestsPerSecond
+	}
+	if override.RateLimit.CharsPerMinute > 0 {
+		c.RateLimit.CharsPerMinute = override.RateLimit.CharsPerMinute
+	}
+	if override.Timeout > 0 {
+		c.Timeout = override.Timeout
+	}
+	return c
+}
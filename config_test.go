@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `# Subsyncer config
+translator = "deepl" # The default provider
+
+[providers.deepl]
+url = "https://api-free.deepl.com"
+key = "deepl-0123456789abcdef"
+chars_per_minute = 100_000
+
+[providers.microsoft]
+region = "westeurope"
+key = 'client-id'
+secret = "client#secret"
+requests_per_second = 0.5
+timeout = "1m30s"
+`
+
+func TestReadConfig(t *testing.T) {
+	config, err := ReadConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reading config, got error: %v", err)
+	}
+
+	if config.Translator != "deepl" {
+		t.Errorf("Expected the deepl translator, got '%s'", config.Translator)
+	}
+
+	deepL := config.Providers["deepl"]
+	if deepL.URL != "https://api-free.deepl.com" || deepL.Key != "deepl-0123456789abcdef" || deepL.RateLimit.CharsPerMinute != 100000 {
+		t.Errorf("Unexpected deepl config: %#v", deepL)
+	}
+
+	microsoft := config.Providers["microsoft"]
+	if microsoft.Region != "westeurope" || microsoft.Key != "client-id" || microsoft.Secret != "client#secret" || microsoft.RateLimit.RequestsPerSecond != 0.5 || microsoft.Timeout != 90*time.Second {
+		t.Errorf("Unexpected microsoft config: %#v", microsoft)
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	cases := []string{
+		"translator deepl",
+		"unknown = 1",
+		"[providers.deepl]\nproxy = \"http://localhost\"",
+		"[providers.deepl]\ntimeout = 10",
+		"[providers.deepl]\nchars_per_minute = \"many\"",
+		"[providers]\nkey = \"key\"",
+		"[translators.deepl]",
+		"translator = \"deepl",
+		"translator = [\"deepl\"]",
+	}
+
+	for _, c := range cases {
+		_, err := ReadConfig(strings.NewReader(c))
+		if err == nil {
+			t.Errorf("Expected an error to occur while reading config '%s'", c)
+		}
+	}
+}
+
+func TestConfigApplyEnv(t *testing.T) {
+	config, _ := ReadConfig(strings.NewReader(testConfig))
+	env := map[string]string{
+		"SUBSYNCER_TRANSLATOR":       "microsoft",
+		"SUBSYNCER_MICROSOFT_SECRET": "env-secret",
+		"SUBSYNCER_GOOGLE_KEY":       "google-key",
+	}
+
+	err := config.applyEnv(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("Expected no error to occur while applying the environment, got error: %v", err)
+	}
+
+	if config.Translator != "microsoft" || config.Providers["microsoft"].Secret != "env-secret" ||
+		config.Providers["microsoft"].Key != "client-id" || config.Providers["google"].Key != "google-key" {
+		t.Errorf("Expected the environment to override the config file, got %+v", config)
+	}
+
+	env = map[string]string{"SUBSYNCER_DEEPL_CHARS_PER_MINUTE": "many"}
+	err = config.applyEnv(func(key string) string { return env[key] })
+	if err == nil {
+		t.Errorf("Expected an error to occur while applying an invalid environment variable")
+	}
+}
+
+func TestTranslatorFlagsResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subsyncer-config")
+	if err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.toml")
+	err = ioutil.WriteFile(path, []byte(testConfig), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	t.Setenv("SUBSYNCER_DEEPL_URL", "https://deepl.example.com")
+	t.Setenv("SUBSYNCER_DEEPL_KEY", "env-key")
+
+	flags := &translatorFlags{configPath: path}
+	flags.config.Key = "flag-key"
+
+	provider, config, err := flags.resolve()
+	if err != nil {
+		t.Fatalf("Expected no error to occur while resolving the translator, got error: %v", err)
+	}
+
+	// Flags override the environment, which overrides the config file
+	if provider != "deepl" || config.Key != "flag-key" || config.URL != "https://deepl.example.com" || config.RateLimit.CharsPerMinute != 100000 {
+		t.Errorf("Unexpected resolved config of %s: %#v", provider, config)
+	}
+
+	flags = &translatorFlags{configPath: filepath.Join(dir, "missing.toml")}
+	provider, _, err = flags.resolve()
+	if err != nil || provider != defaultTranslationProvider {
+		t.Errorf("Expected the default translator with no config file, got %s (error: %v)", provider, err)
+	}
+}
+
+func TestConfigRedaction(t *testing.T) {
+	config, _ := ReadConfig(strings.NewReader(testConfig))
+
+	var buffer bytes.Buffer
+	err := config.Write(&buffer)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while writing config, got error: %v", err)
+	}
+
+	written := buffer.String() + fmt.Sprint(config.Providers["deepl"]) + fmt.Sprintf("%+v", config.Providers["microsoft"])
+	for _, secret := range []string{"deepl-0123456789abcdef", "client-id", "client#secret"} {
+		if strings.Contains(written, secret) {
+			t.Errorf("Expected secret '%s' to be redacted, got:\n%s", secret, written)
+		}
+	}
+
+	if !strings.Contains(buffer.String(), `region = "westeurope"`) {
+		t.Errorf("Expected the region to be written, got:\n%s", buffer.String())
+	}
+
+	if !strings.Contains(buffer.String(), `key = "********cdef"`) {
+		t.Errorf("Expected long keys to be told apart by their last characters, got:\n%s", buffer.String())
+	}
+
+	// The written config can be read back
+	_, err = ReadConfig(&buffer)
+	if err != nil {
+		t.Errorf("Expected no error to occur while reading the written config, got error: %v", err)
+	}
+}
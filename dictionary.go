@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	tsvDictionary         = "tsv"
+	freeDictDictionary    = "freedict"
+	wiktextractDictionary = "wiktextract"
+)
+
+var (
+	// dictionaryWordRegexp matches the words translated by dictionaries.
+	dictionaryWordRegexp = regexp.MustCompile(`[\p{L}\p{M}\p{N}]+(['’][\p{L}\p{M}\p{N}]+)*`)
+)
+
+func init() {
+	registerTranslationProvider(&translationProvider{
+		name:        "dictionary",
+		description: "Offline word-by-word translation using a local bilingual dictionary file",
+		batch:       BatchOptions{MaxChars: 1000000, MaxItems: 10000},
+		newClient: func(config ProviderConfig) (TextTranslator, error) {
+			if config.Dictionary == "" {
+				return nil, fmt.Errorf("The dictionary translator requires a dictionary file")
+			}
+
+			_, err := dictionaryFormat(config.Dictionary)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = os.Stat(config.Dictionary)
+			if err != nil {
+				return nil, err
+			}
+
+			return &dictionaryClient{
+				path:         config.Dictionary,
+				dictionaries: make(map[string]*Dictionary),
+			}, nil
+		},
+	})
+}
+
+// Dictionary is a bilingual lexicon, translating words and phrases of one language into another.
+type Dictionary struct {
+	// entries maps lower case words and phrases to their translations.
+	entries map[string]string
+
+	// lemmas maps the analyzed forms of single words to their translations,
+	// allowing to translate inflected forms missing from the lexicon.
+	lemmas map[string]string
+
+	// maxPhraseWords is the number of words of the longest phrase in the lexicon.
+	maxPhraseWords int
+
+	// lemma converts a word into its analyzed form, or returns an empty string for stop words.
+	lemma func(word string) string
+}
+
+// LoadDictionary loads a dictionary translating from one language to another from the given file.
+// The file format is selected by its extension: ".tsv", ".tab" or ".txt" for tab-separated values,
+// ".tei" or ".xml" for FreeDict TEI dictionaries, and ".jsonl" or ".json" for Wiktextract dumps.
+func LoadDictionary(path string, from, to string) (*Dictionary, error) {
+	format, err := dictionaryFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadDictionary(file, format, from, to)
+}
+
+// ReadDictionary reads a dictionary in the given format from the given stream. The "tsv" format
+// holds a word or phrase per line, followed by a tab and its translation, with alternative translations,
+// separated by semicolons or tabs, ignored, and lines starting with '#' skipped. For the "freedict" and
+// "wiktextract" formats, the first translation of each entry into the target language is used.
+func ReadDictionary(reader io.Reader, format string, from, to string) (*Dictionary, error) {
+	d := &Dictionary{
+		entries: make(map[string]string),
+		lemmas:  make(map[string]string),
+	}
+
+	var err error
+	switch format {
+	case tsvDictionary:
+		err = d.readTSV(reader)
+	case freeDictDictionary:
+		err = d.readFreeDict(reader)
+	case wiktextractDictionary:
+		err = d.readWiktextract(reader, from, to)
+	default:
+		err = fmt.Errorf("Unknown dictionary format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.entries) == 0 {
+		return nil, fmt.Errorf("No dictionary entries found")
+	}
+
+	d.lemma, err = newLemmatizer(from)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]string, 0, len(d.entries))
+	for phrase := range d.entries {
+		if !strings.Contains(phrase, " ") {
+			words = append(words, phrase)
+		}
+	}
+	sort.Strings(words)
+
+	for _, word := range words {
+		lemma := d.lemma(word)
+		if lemma == "" {
+			continue
+		}
+
+		// Translations of words which are their own lemma take precedence over those of inflected forms.
+		if _, ok := d.lemmas[lemma]; !ok || lemma == word {
+			d.lemmas[lemma] = d.entries[word]
+		}
+	}
+
+	return d, nil
+}
+
+// Translate translates the given text word by word, preferring the longest phrases found in
+// the lexicon, and falling back to the analyzed form of words. Words with no translation,
+// such as names, are kept as is.
+func (d *Dictionary) Translate(text string) string {
+	locations := dictionaryWordRegexp.FindAllStringIndex(text, -1)
+	words := make([]string, len(locations))
+	for i, loc := range locations {
+		words[i] = strings.ToLower(text[loc[0]:loc[1]])
+	}
+
+	result := make([]string, 0, 2*len(words)+1)
+	end := 0
+	for i := 0; i < len(words); {
+		result = append(result, text[end:locations[i][0]])
+
+		translation, n := d.lookup(words[i:])
+		if n == 0 {
+			translation, n = text[locations[i][0]:locations[i][1]], 1
+		}
+
+		result = append(result, translation)
+		end = locations[i+n-1][1]
+		i += n
+	}
+	result = append(result, text[end:])
+
+	return strings.Join(result, "")
+}
+
+// lookup finds the translation of the longest phrase starting at the given words,
+// returning the translation and the number of words translated, or zero if none.
+func (d *Dictionary) lookup(words []string) (string, int) {
+	for n := minInt(d.maxPhraseWords, len(words)); n > 0; n-- {
+		if translation, ok := d.entries[strings.Join(words[:n], " ")]; ok {
+			return translation, n
+		}
+	}
+
+	if lemma := d.lemma(words[0]); lemma != "" {
+		if translation, ok := d.lemmas[lemma]; ok {
+			return translation, 1
+		}
+	}
+
+	return "", 0
+}
+
+// add adds the given phrase and its translation to the dictionary, unless already there.
+func (d *Dictionary) add(phrase, translation string) {
+	phrase = strings.Join(dictionaryWordRegexp.FindAllString(strings.ToLower(phrase), -1), " ")
+	translation = strings.TrimSpace(translation)
+	if phrase == "" || translation == "" {
+		return
+	}
+
+	if _, ok := d.entries[phrase]; ok {
+		return
+	}
+
+	d.entries[phrase] = translation
+	if n := strings.Count(phrase, " ") + 1; n > d.maxPhraseWords {
+		d.maxPhraseWords = n
+	}
+}
+
+func (d *Dictionary) readTSV(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return fmt.Errorf("Invalid dictionary entry at line %d: expected a tab separated translation", lineNumber)
+		}
+
+		d.add(fields[0], strings.Split(fields[1], ";")[0])
+	}
+
+	return scanner.Err()
+}
+
+type freeDictEntry struct {
+	Orths  []string `xml:"form>orth"`
+	Senses []struct {
+		Cits []struct {
+			Type   string   `xml:"type,attr"`
+			Quotes []string `xml:"quote"`
+		} `xml:"cit"`
+	} `xml:"sense"`
+}
+
+func (d *Dictionary) readFreeDict(reader io.Reader) error {
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "entry" {
+			continue
+		}
+
+		entry := &freeDictEntry{}
+		err = decoder.DecodeElement(entry, &start)
+		if err != nil {
+			return err
+		}
+
+		if translation := entry.translation(); translation != "" {
+			for _, orth := range entry.Orths {
+				d.add(orth, translation)
+			}
+		}
+	}
+}
+
+// translation returns the first translation of the entry, or an empty string if none.
+func (e *freeDictEntry) translation() string {
+	for _, sense := range e.Senses {
+		for _, cit := range sense.Cits {
+			if cit.Type == "trans" && len(cit.Quotes) > 0 {
+				return cit.Quotes[0]
+			}
+		}
+	}
+
+	return ""
+}
+
+type wiktextractTranslation struct {
+	Code string `json:"code"`
+	Word string `json:"word"`
+}
+
+type wiktextractEntry struct {
+	Word         string                   `json:"word"`
+	LangCode     string                   `json:"lang_code"`
+	Translations []wiktextractTranslation `json:"translations"`
+	Senses       []struct {
+		Translations []wiktextractTranslation `json:"translations"`
+	} `json:"senses"`
+}
+
+func (d *Dictionary) readWiktextract(reader io.Reader, from, to string) error {
+	from, to = analyzerLanguage(from), analyzerLanguage(to)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry := &wiktextractEntry{}
+		err := json.Unmarshal([]byte(line), entry)
+		if err != nil {
+			return fmt.Errorf("Invalid dictionary entry at line %d: %v", lineNumber, err)
+		}
+
+		if entry.LangCode != "" && from != "" && analyzerLanguage(entry.LangCode) != from {
+			continue
+		}
+
+		translations := entry.Translations
+		for _, sense := range entry.Senses {
+			translations = append(translations, sense.Translations...)
+		}
+
+		for _, translation := range translations {
+			if analyzerLanguage(translation.Code) == to {
+				d.add(entry.Word, translation.Word)
+				break
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// dictionaryFormat selects the format of the given dictionary file by its extension.
+func dictionaryFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsv", ".tab", ".txt":
+		return tsvDictionary, nil
+	case ".tei", ".xml":
+		return freeDictDictionary, nil
+	case ".jsonl", ".json":
+		return wiktextractDictionary, nil
+	default:
+		return "", fmt.Errorf("Unknown dictionary format of %s: expected a .tsv, .tei or .jsonl file", path)
+	}
+}
+
+// newLemmatizer creates a function converting words of the given language into their analyzed form,
+// using the language analyzer of the index.
+func newLemmatizer(language string) (func(word string) string, error) {
+	indexMapping, err := newIndexMapping(language)
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer := indexMapping.AnalyzerNamed(indexMapping.DefaultAnalyzer)
+	return func(word string) string {
+		tokens := analyzer.Analyze([]byte(word))
+		if len(tokens) == 0 {
+			return ""
+		}
+		return string(tokens[0].Term)
+	}, nil
+}
+
+// dictionaryClient is a TextTranslator using a dictionary file, loaded once for each language pair.
+type dictionaryClient struct {
+	path string
+
+	mutex        sync.Mutex
+	dictionaries map[string]*Dictionary
+}
+
+func (c *dictionaryClient) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	dictionary, err := c.dictionary(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make([]string, len(texts))
+	for i, text := range texts {
+		translations[i] = dictionary.Translate(text)
+	}
+
+	return translations, nil
+}
+
+// dictionary returns the dictionary translating from one language to another, loading it if needed.
+func (c *dictionaryClient) dictionary(from, to string) (*Dictionary, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := from + ":" + to
+	if dictionary, ok := c.dictionaries[key]; ok {
+		return dictionary, nil
+	}
+
+	dictionary, err := LoadDictionary(c.path, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	c.dictionaries[key] = dictionary
+	return dictionary, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTSVDictionary = `# English-French
+good	bon;bonne
+house	maison
+in the morning	le matin
+walk	marcher
+`
+
+const testFreeDictDictionary = `<?xml version="1.0" encoding="UTF-8"?>
+<TEI xmlns="http://www.tei-c.org/ns/1.0">
+  <text><body>
+    <entry>
+      <form><orth>good</orth></form>
+      <sense><cit type="trans"><quote>bon</quote><quote>bonne</quote></cit></sense>
+    </entry>
+    <entry>
+      <form><orth>house</orth></form>
+      <sense><cit type="example"><quote>a big house</quote></cit><cit type="trans"><quote>maison</quote></cit></sense>
+    </entry>
+    <entry>
+      <form><orth>in the morning</orth></form>
+      <sense><cit type="trans"><quote>le matin</quote></cit></sense>
+    </entry>
+    <entry>
+      <form><orth>walk</orth></form>
+      <sense><cit type="trans"><quote>marcher</quote></cit></sense>
+    </entry>
+  </body></text>
+</TEI>
+`
+
+const testWiktextractDictionary = `{"word": "good", "lang_code": "en", "senses": [{"translations": [{"code": "es", "word": "bueno"}, {"code": "fr", "word": "bon"}]}]}
+{"word": "house", "lang_code": "en", "translations": [{"code": "fr", "word": "maison"}]}
+{"word": "in the morning", "lang_code": "en", "translations": [{"code": "fr", "word": "le matin"}]}
+{"word": "walk", "lang_code": "en", "translations": [{"code": "fr", "word": "marcher"}]}
+{"word": "bob", "lang_code": "es", "translations": [{"code": "fr", "word": "pierre"}]}
+`
+
+func TestDictionaryTranslate(t *testing.T) {
+	cases := []struct {
+		format string
+		data   string
+	}{
+		{tsvDictionary, testTSVDictionary},
+		{freeDictDictionary, testFreeDictDictionary},
+		{wiktextractDictionary, testWiktextractDictionary},
+	}
+
+	for _, c := range cases {
+		dictionary, err := ReadDictionary(strings.NewReader(c.data), c.format, "en", "fr")
+		if err != nil {
+			t.Fatalf("Expected no error to occur while reading %s dictionary, got error: %v", c.format, err)
+		}
+
+		// Phrases are preferred over words, and inflected forms fall back to their lemma
+		expected := "bon maison, le matin! marcher Bob."
+		actual := dictionary.Translate("Good houses, in the morning! Walked Bob.")
+		if actual != expected {
+			t.Errorf("Expected %s dictionary translation '%s', got '%s'", c.format, expected, actual)
+		}
+	}
+}
+
+func TestReadDictionaryErrors(t *testing.T) {
+	_, err := ReadDictionary(strings.NewReader("good bon\n"), tsvDictionary, "en", "fr")
+	if err == nil {
+		t.Errorf("Expected an error to occur while reading a dictionary with no tab separated translation")
+	}
+
+	_, err = ReadDictionary(strings.NewReader(testWiktextractDictionary), wiktextractDictionary, "en", "de")
+	if err == nil {
+		t.Errorf("Expected an error to occur while reading a dictionary with no translations to the target language")
+	}
+
+	_, err = LoadDictionary("dictionary.pdf", "en", "fr")
+	if err == nil {
+		t.Errorf("Expected an error to occur while loading a dictionary of an unknown format")
+	}
+}
+
+func TestDictionaryTranslator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subsyncer-dictionary")
+	if err != nil {
+		t.Fatalf("Failed to create dictionary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "en-fr.tsv")
+	err = ioutil.WriteFile(path, []byte(testTSVDictionary), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write dictionary: %v", err)
+	}
+
+	_, err = NewProviderTranslator("dictionary", ProviderConfig{}, TranslateOptions{})
+	if err == nil {
+		t.Errorf("Expected an error to occur while using the dictionary translator with no dictionary")
+	}
+
+	translator, err := NewProviderTranslator("dictionary", ProviderConfig{Dictionary: path}, TranslateOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating the dictionary translator, got error: %v", err)
+	}
+
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Text: []string{"Good morning"}},
+			{Index: 2, Text: []string{"A good house,", "in the morning"}},
+		},
+	}
+
+	tSubtitle, _, err := translator.Translate(subtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	for i, expected := range []string{"bon morning", "A bon maison, le matin"} {
+		if actual := strings.Join(tSubtitle.Entries[i].Text, " "); actual != expected {
+			t.Errorf("Expected entry %d to be translated to '%s', got '%s'", i+1, expected, actual)
+		}
+	}
+}
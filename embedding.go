@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	embeddingBackend = "embedding"
+
+	// minEmbeddingSimilarity is the minimal cosine similarity of entries matched by the embedding backend.
+	minEmbeddingSimilarity = 0.7
+)
+
+// WordEmbeddings maps words to pre-computed embedding vectors, all of the same dimension.
+type WordEmbeddings struct {
+	Dimension int
+	vectors   map[string][]float32
+}
+
+// LoadWordEmbeddings loads word embeddings from the given file, in the text format used by
+// word2vec and fastText: one word per line, followed by its vector components, separated by spaces.
+// An optional first line holding the number of words and the dimension is skipped.
+func LoadWordEmbeddings(path string) (*WordEmbeddings, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadWordEmbeddings(file)
+}
+
+// ReadWordEmbeddings reads word embeddings from the given stream, as described by LoadWordEmbeddings.
+func ReadWordEmbeddings(reader io.Reader) (*WordEmbeddings, error) {
+	we := &WordEmbeddings{
+		vectors: make(map[string][]float32),
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Skip the header line
+		if lineNumber == 1 && len(fields) == 2 {
+			continue
+		}
+
+		if we.Dimension == 0 {
+			we.Dimension = len(fields) - 1
+		}
+
+		if len(fields)-1 != we.Dimension {
+			return nil, fmt.Errorf("Invalid embedding at line %d: expected %d components, got %d",
+				lineNumber, we.Dimension, len(fields)-1)
+		}
+
+		vector := make([]float32, we.Dimension)
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseFloat(field, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid embedding at line %d: %v", lineNumber, err)
+			}
+			vector[i] = float32(v)
+		}
+
+		we.vectors[strings.ToLower(fields[0])] = vector
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	if len(we.vectors) == 0 {
+		return nil, fmt.Errorf("No word embeddings found")
+	}
+
+	return we, nil
+}
+
+// SentenceVector computes the embedding of the given text, as the normalized average of the vectors
+// of its words. It returns nil if none of the words has an embedding.
+func (we *WordEmbeddings) SentenceVector(text string) []float64 {
+	sum := make([]float64, we.Dimension)
+	found := false
+	for _, word := range strings.Fields(normalizeNgramText(text)) {
+		vector, ok := we.vectors[word]
+		if !ok {
+			continue
+		}
+
+		found = true
+		for i, v := range vector {
+			sum[i] += float64(v)
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	length := 0.0
+	for _, v := range sum {
+		length += v * v
+	}
+	length = math.Sqrt(length)
+	if length == 0 {
+		return nil
+	}
+
+	for i := range sum {
+		sum[i] /= length
+	}
+
+	return sum
+}
+
+// embeddingIndexedSubtitle is an IndexedSubtitle matching text by the cosine similarity of
+// sentence embeddings, allowing to match paraphrases with no words in common.
+type embeddingIndexedSubtitle struct {
+	subtitle   *SubtitleFile
+	embeddings *WordEmbeddings
+
+	// vectors holds the sentence vector of each entry, by entry position.
+	vectors [][]float64
+}
+
+func newEmbeddingIndexedSubtitle(subtitle *SubtitleFile, options IndexOptions) (IndexedSubtitle, error) {
+	if options.Embeddings == nil {
+		return nil, fmt.Errorf("The embedding index backend requires word embeddings")
+	}
+
+	eis := &embeddingIndexedSubtitle{
+		subtitle:   subtitle,
+		embeddings: options.Embeddings,
+		vectors:    make([][]float64, len(subtitle.Entries)),
+	}
+
+	ads := options.Ads.Ads(subtitle)
+	for i, entry := range subtitle.Entries {
+		if ads != nil && ads[i] {
+			continue
+		}
+
+		eis.vectors[i] = eis.embeddings.SentenceVector(matchText(entry.Text))
+	}
+
+	return eis, nil
+}
+
+func (eis *embeddingIndexedSubtitle) Search(text string) (*SubtitleEntry, error) {
+	results, err := eis.SearchCandidates(text, 1)
+	if err == ErrNoHits || err == ErrBelowThreshold {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results[0].Entry, nil
+}
+
+func (eis *embeddingIndexedSubtitle) SearchCandidates(text string, k int) ([]*SearchResult, error) {
+	return eis.search(text, k, nil)
+}
+
+func (eis *embeddingIndexedSubtitle) SearchNear(text string, k int, at, radius time.Duration) ([]*SearchResult, error) {
+	return eis.search(text, k, func(entry *SubtitleEntry) float64 {
+		if entry.End < at-radius || entry.Start > at+radius {
+			return 0
+		}
+		return proximity(entry, at, radius)
+	})
+}
+
+func (eis *embeddingIndexedSubtitle) Close() error {
+	return nil
+}
+
+// search finds the entries nearest to the given text by cosine similarity, returning up to k results
+// as described by SearchCandidates. If boost is not nil, result scores are multiplied by the boost
+// of their entry, and entries with a zero boost are excluded.
+func (eis *embeddingIndexedSubtitle) search(text string, k int, boost func(entry *SubtitleEntry) float64) ([]*SearchResult, error) {
+	text = matchText([]string{text})
+	query := eis.embeddings.SentenceVector(text)
+	if query == nil {
+		return nil, ErrNoHits
+	}
+
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(normalizeNgramText(text)) {
+		words[word] = true
+	}
+
+	results := make([]*SearchResult, 0, len(eis.vectors))
+	for i, vector := range eis.vectors {
+		if vector == nil {
+			continue
+		}
+
+		// Both vectors are normalized, so their dot product is their cosine similarity.
+		score := 0.0
+		for j, v := range vector {
+			score += v * query[j]
+		}
+		if score <= 0 {
+			continue
+		}
+
+		entry := eis.subtitle.Entries[i]
+		result := &SearchResult{
+			Entry:        entry,
+			Score:        score,
+			MatchedTerms: sharedWords(words, matchText(entry.Text)),
+			rawScore:     score,
+		}
+		if boost != nil {
+			result.Score *= boost(entry)
+			if result.Score == 0 {
+				continue
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return rankResults(results, minEmbeddingSimilarity, k)
+}
+
+// sharedWords returns the words of the given text which are also in the given set of words, sorted.
+func sharedWords(words map[string]bool, text string) []string {
+	shared := make([]string, 0, len(words))
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(normalizeNgramText(text)) {
+		if words[word] && !seen[word] {
+			seen[word] = true
+			shared = append(shared, word)
+		}
+	}
+
+	sort.Strings(shared)
+	return shared
+}
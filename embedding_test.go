@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testEmbeddings = `10 3
+leave 0.9 0.1 0.0
+now 0.8 0.0 0.2
+get 0.9 0.0 0.1
+out 1.0 0.1 0.0
+here 0.7 0.2 0.1
+love 0.0 1.0 0.1
+heart 0.1 0.9 0.0
+hungry 0.0 0.1 1.0
+eat 0.1 0.0 0.9
+food 0.0 0.2 0.9
+`
+
+func TestReadWordEmbeddings(t *testing.T) {
+	embeddings, err := ReadWordEmbeddings(strings.NewReader(testEmbeddings))
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reading embeddings, got error: %v", err)
+	}
+
+	if embeddings.Dimension != 3 {
+		t.Errorf("Expected embeddings dimension to be 3, got %d", embeddings.Dimension)
+	}
+
+	if embeddings.SentenceVector("Unknown words only") != nil {
+		t.Errorf("Expected no sentence vector for text with no known words")
+	}
+
+	_, err = ReadWordEmbeddings(strings.NewReader("love 0.1 0.2 0.3\nheart 0.1 0.2\n"))
+	if err == nil {
+		t.Errorf("Expected an error to occur while reading embeddings of inconsistent dimensions")
+	}
+}
+
+func TestEmbeddingIndexedSubtitleSearch(t *testing.T) {
+	embeddings, err := ReadWordEmbeddings(strings.NewReader(testEmbeddings))
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reading embeddings, got error: %v", err)
+	}
+
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("1m"), End: mustParseDuration("1m2s"), Text: []string{"Leave now!"}},
+			{Index: 2, Start: mustParseDuration("2m"), End: mustParseDuration("2m2s"), Text: []string{"You are in my heart."}},
+			{Index: 3, Start: mustParseDuration("3m"), End: mustParseDuration("3m2s"), Text: []string{"I'm hungry, let's eat."}},
+			{Index: 4, Start: mustParseDuration("9m"), End: mustParseDuration("9m2s"), Text: []string{"Get out!"}},
+		},
+	}
+
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en", Backend: embeddingBackend, Embeddings: embeddings})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	cases := []struct {
+		text  string
+		index int
+	}{
+		{"I love you", 2},
+		{"Where's the food?", 3},
+	}
+
+	for i, c := range cases {
+		resEntry, err := indexedSub.Search(c.text)
+		if err != nil {
+			t.Fatalf("Got error while searching (case %d): %v", i, err)
+		}
+
+		if resEntry == nil || resEntry.Index != c.index {
+			t.Errorf("Expected entry %d to be found (case %d), got %v", c.index, i, resEntry)
+		}
+	}
+
+	results, err := indexedSub.SearchNear("Get out of here!", 2, mustParseDuration("1m30s"), mustParseDuration("2m"))
+	if err != nil {
+		t.Fatalf("Got error while searching near: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Entry.Index != 1 {
+		t.Errorf("Expected only entry 1 to be found near, got %v", results)
+	}
+
+	_, err = indexedSub.SearchCandidates("Nothing known", 2)
+	if err != ErrNoHits {
+		t.Errorf("Expected ErrNoHits while searching unknown words, got %v", err)
+	}
+}
+
+func TestEmbeddingIndexedSubtitleNoEmbeddings(t *testing.T) {
+	_, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en", Backend: embeddingBackend})
+	if err == nil {
+		t.Errorf("Expected an error to occur while using the embedding backend with no embeddings")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	utf8Encoding        = "UTF-8"
+	utf8BOMEncoding     = "UTF-8 with BOM"
+	utf16LEEncoding     = "UTF-16LE"
+	utf16BEEncoding     = "UTF-16BE"
+	windows1252Encoding = "Windows-1252"
+)
+
+// subtitleFormat is a subtitle file format, selectable by name.
+type subtitleFormat struct {
+	name        string
+	description string
+
+	// extension is the file name extension of the format, including the dot.
+	extension string
+
+	// newParser creates a reader and writer of the format.
+	newParser func() SubtitleReaderWriter
+}
+
+var (
+	srtFormat = &subtitleFormat{
+		name:        "srt",
+		description: "SubRip",
+		extension:   ".srt",
+		newParser:   func() SubtitleReaderWriter { return &SRTParser{} },
+	}
+
+	vttFormat = &subtitleFormat{
+		name:        "vtt",
+		description: "WebVTT",
+		extension:   ".vtt",
+		newParser:   func() SubtitleReaderWriter { return &VTTParser{} },
+	}
+
+	subtitleFormats = []*subtitleFormat{srtFormat, vttFormat}
+
+	// windows1252 maps the bytes 0x80-0x9F of Windows-1252 to runes. Other bytes map to the
+	// runes of the same value, as in ISO 8859-1.
+	windows1252 = [32]rune{
+		'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+		0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+	}
+)
+
+// subtitleSource describes where and how a subtitle file was read.
+type subtitleSource struct {
+	// path is the path of the subtitle file, or empty if read from the standard input.
+	path string
+
+	format   *subtitleFormat
+	encoding string
+}
+
+// lookupSubtitleFormat returns the subtitle format of the given name.
+func lookupSubtitleFormat(name string) (*subtitleFormat, error) {
+	for _, format := range subtitleFormats {
+		if strings.EqualFold(name, format.name) {
+			return format, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown subtitle format: %s (available: %s)", name, strings.Join(subtitleFormatNames(), ", "))
+}
+
+// subtitleFormatOfPath returns the subtitle format of the given path by its extension,
+// or nil if it has no known subtitle file extension.
+func subtitleFormatOfPath(path string) *subtitleFormat {
+	ext := filepath.Ext(path)
+	for _, format := range subtitleFormats {
+		if strings.EqualFold(ext, format.extension) {
+			return format
+		}
+	}
+
+	return nil
+}
+
+// subtitleFormatNames returns the names of all subtitle formats.
+func subtitleFormatNames() []string {
+	names := make([]string, len(subtitleFormats))
+	for i, format := range subtitleFormats {
+		names[i] = format.name
+	}
+
+	return names
+}
+
+// detectSubtitleFormat determines the format of the given subtitle file text, read from the given
+// path: WebVTT files start with a header, and other files are assumed to be SRT files, unless
+// their extension says otherwise.
+func detectSubtitleFormat(path, text string) *subtitleFormat {
+	if strings.HasPrefix(text, "WEBVTT") {
+		return vttFormat
+	}
+
+	if format := subtitleFormatOfPath(path); format != nil {
+		return format
+	}
+
+	return srtFormat
+}
+
+// decodeSubtitleText decodes the given subtitle file contents into text, returning the detected
+// encoding along with it. UTF-8 and UTF-16 are detected by their byte order marks, and contents
+// which aren't valid UTF-8 are assumed to be Windows-1252, the most common legacy encoding of
+// subtitle files.
+func decodeSubtitleText(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), utf8BOMEncoding
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false), utf16LEEncoding
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true), utf16BEEncoding
+	case utf8.Valid(data):
+		return string(data), utf8Encoding
+	default:
+		return decodeWindows1252(data), windows1252Encoding
+	}
+}
+
+// decodeUTF16 decodes the given UTF-16 text, ignoring a trailing odd byte.
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// decodeWindows1252 decodes the given Windows-1252 text.
+func decodeWindows1252(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		if b >= 0x80 && b < 0xA0 {
+			runes[i] = windows1252[b-0x80]
+		} else {
+			runes[i] = rune(b)
+		}
+	}
+
+	return string(runes)
+}
+
+// readSubtitleFile reads the subtitle file at the given path, or from the standard input if the
+// path is empty or "-", detecting its format and encoding.
+func readSubtitleFile(path string) (*SubtitleFile, *subtitleSource, error) {
+	var data []byte
+	var err error
+	source := &subtitleSource{}
+	if path == "" || path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		source.path = path
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	text, encoding := decodeSubtitleText(data)
+	source.encoding = encoding
+	source.format = detectSubtitleFormat(path, text)
+
+	subtitle, err := source.format.newParser().Read(strings.NewReader(text))
+	if err != nil {
+		if source.path != "" {
+			return nil, nil, fmt.Errorf("Failed to read %s: %v", source.path, err)
+		}
+		return nil, nil, err
+	}
+
+	return subtitle, source, nil
+}
+
+// writeSubtitleFile writes the given subtitle file in the given format, in UTF-8, to the given path,
+// or to the standard output if the path is empty or "-". Files are written to a temporary file
+// which then replaces the file at the path, so that a failure leaves the file intact.
+func writeSubtitleFile(subtitle *SubtitleFile, path string, format *subtitleFormat) error {
+	parser := format.newParser()
+	if path == "" || path == "-" {
+		return parser.Write(subtitle, os.Stdout)
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	err = parser.Write(subtitle, file)
+	if err == nil {
+		// Keep the permissions of overwritten files
+		mode := os.FileMode(0644)
+		if info, statErr := os.Stat(path); statErr == nil {
+			mode = info.Mode().Perm()
+		}
+		err = file.Chmod(mode)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeSubtitleText(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		text     string
+		encoding string
+	}{
+		{[]byte("Café"), "Café", utf8Encoding},
+		{[]byte("\xEF\xBB\xBFCafé"), "Café", utf8BOMEncoding},
+		{[]byte{0xFF, 0xFE, 'C', 0, 'a', 0, 'f', 0, 0xE9, 0}, "Café", utf16LEEncoding},
+		{[]byte{0xFE, 0xFF, 0, 'C', 0, 'a', 0, 'f', 0, 0xE9}, "Café", utf16BEEncoding},
+		{[]byte("Caf\xE9 \x93quoted\x94 \x80"), "Café “quoted” €", windows1252Encoding},
+	}
+
+	for _, test := range tests {
+		text, encoding := decodeSubtitleText(test.data)
+		if text != test.text || encoding != test.encoding {
+			t.Errorf("Expected %q to decode as %q (%s), got %q (%s)", test.data, test.text, test.encoding, text, encoding)
+		}
+	}
+}
+
+func TestDetectSubtitleFormat(t *testing.T) {
+	tests := []struct {
+		path   string
+		text   string
+		format string
+	}{
+		{"movie.srt", "1\n00:00:01,000 --> 00:00:02,000\n", "srt"},
+		{"movie.vtt", "WEBVTT\n\n", "vtt"},
+		{"movie.srt", "WEBVTT\n\n", "vtt"},
+		{"", "WEBVTT - Movie\n\n", "vtt"},
+		{"", "1\n00:00:01,000 --> 00:00:02,000\n", "srt"},
+		{"movie.VTT", "", "vtt"},
+	}
+
+	for _, test := range tests {
+		format := detectSubtitleFormat(test.path, test.text)
+		if format.name != test.format {
+			t.Errorf("Expected %s (%q) to be detected as %s, got %s", test.path, test.text, test.format, format.name)
+		}
+	}
+}
+
+func TestReadWriteSubtitleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subsyncer-format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "movie.srt")
+	err = ioutil.WriteFile(path, []byte("1\r\n00:00:01,000 --> 00:00:02,000\r\nCaf\xE9\r\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subtitle, source, err := readSubtitleFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reading subtitle, got error: %v", err)
+	}
+
+	if source.format != srtFormat || source.encoding != windows1252Encoding {
+		t.Errorf("Expected an SRT file in Windows-1252, got %s in %s", source.format.name, source.encoding)
+	}
+	assertEntry(t, subtitle.Entries[0], 1, "1s", "2s", "Café")
+
+	err = writeSubtitleFile(subtitle, path, vttFormat)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while writing subtitle, got error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the permissions of the overwritten file to be kept, got %v", info.Mode().Perm())
+	}
+
+	subtitle, source, err = readSubtitleFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reading written subtitle, got error: %v", err)
+	}
+
+	if source.format != vttFormat || source.encoding != utf8Encoding {
+		t.Errorf("Expected a WebVTT file in UTF-8, got %s in %s", source.format.name, source.encoding)
+	}
+	assertEntry(t, subtitle.Entries[0], 1, "1s", "2s", "Café")
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected no temporary files to be left, got %d files", len(files))
+	}
+}
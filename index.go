@@ -1,62 +1,323 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search"
 	"github.com/blevesearch/bleve/search/query"
 )
 
 const (
+	bleveBackend = "bleve"
+	ngramBackend = "ngram"
+
 	minHitScore = 0.25
+
+	// proximityBoost is the maximal score penalty of entries found by SearchNear,
+	// for entries at the edge of the searched time window.
+	proximityBoost = 0.5
+
+	// boostedSearchFactor is the number of hits considered by boosted searches, per requested result.
+	boostedSearchFactor = 4
+
+	// indexBatchSize is the number of entries indexed by the bleve backend in a single batch.
+	indexBatchSize = 500
 )
 
+var (
+	// ErrNoHits is returned when no indexed entry matches the searched text.
+	ErrNoHits = errors.New("No matching subtitle entries")
+
+	// ErrBelowThreshold is returned when no indexed entry matches the searched text with
+	// a score of at least the minimal score of the index backend.
+	ErrBelowThreshold = errors.New("No subtitle entries matched above the minimal score")
+)
+
+// IndexedSubtitle is a subtitle file indexed for searching by text.
+// Its methods, other than Close, are safe for concurrent use.
 type IndexedSubtitle interface {
+	// Search returns the entry best matching the given text, or nil if there's no good enough match.
 	Search(text string) (*SubtitleEntry, error)
+
+	// SearchCandidates returns up to k entries matching the given text, ordered by descending score.
+	// It returns ErrNoHits if no entry matches the text. If no entry scores at least the minimal
+	// score of the backend, it returns ErrBelowThreshold along with the low scoring candidates.
+	SearchCandidates(text string, k int) ([]*SearchResult, error)
+
+	// SearchNear is like SearchCandidates, but only considers entries overlapping the time window
+	// [at-radius, at+radius], and ranks them by their score boosted by their proximity to at.
+	// The boost factor decreases linearly from 1 for entries at the given time, down to
+	// 1-proximityBoost for entries at the edges of the window.
+	SearchNear(text string, k int, at, radius time.Duration) ([]*SearchResult, error)
+
+	// Close releases the resources held by the index.
+	Close() error
+}
+
+// SearchResult is a candidate entry found when searching an IndexedSubtitle.
+type SearchResult struct {
+	Entry *SubtitleEntry
+
+	// Score is the relevance score of the entry, as computed by the index,
+	// and boosted by temporal proximity for SearchNear.
+	Score float64
+
+	// NormalizedScore is the score relative to the top scoring candidate, between 0 and 1.
+	NormalizedScore float64
+
+	// MatchedTerms are the analyzed terms of the searched text found in the entry, sorted.
+	MatchedTerms []string
+
+	// rawScore is the score before boosting, which is compared to the minimal score.
+	rawScore float64
+}
+
+// IndexOptions control how a subtitle file is indexed and searched.
+type IndexOptions struct {
+	// Language is the language of the subtitle text, e.g. "en" or "eng".
+	Language string
+
+	// Backend selects the index implementation: "bleve" for term based search, "ngram" for
+	// character n-gram similarity, or "embedding" for semantic similarity. If empty, it is selected
+	// by language: "ngram" for languages written without spaces between words, such as Chinese,
+	// Japanese or Thai, and "bleve" otherwise.
+	Backend string
+
+	// Fuzziness is the maximal edit distance between searched and indexed terms for them to match,
+	// allowing to match translation variants. Zero means exact term matching. Used by the bleve backend.
+	Fuzziness int
+
+	// Similarity selects the similarity measure of the ngram backend: "cosine" (default),
+	// "jaccard" or "edit".
+	Similarity string
+
+	// Embeddings are the word embeddings used by the embedding backend.
+	Embeddings *WordEmbeddings
+
+	// CacheDir is the directory in which the bleve backend persists indexes, so that they are
+	// reused when indexing the same subtitle file again. If empty, indexes are kept in memory only.
+	CacheDir string
+
+	// Source identifies the indexed subtitle file, e.g. by its path, so that its persistent indexes
+	// are pruned once superseded by an index of a modified version of it. It may be empty if unknown.
+	Source string
+
+	// Ads detects advertisement and credit entries, which are left out of the index so that they
+	// are never matched. If nil, all entries are indexed.
+	Ads *AdDetector
+}
+
+// NewIndexedSubtitle indexes the given subtitle file for searching by text.
+func NewIndexedSubtitle(subtitle *SubtitleFile, options IndexOptions) (IndexedSubtitle, error) {
+	backend := options.Backend
+	if backend == "" {
+		backend = defaultIndexBackend(options.Language)
+	}
+
+	switch backend {
+	case bleveBackend:
+		return newBleveIndexedSubtitle(subtitle, options)
+	case ngramBackend:
+		return newNgramIndexedSubtitle(subtitle, options)
+	case embeddingBackend:
+		return newEmbeddingIndexedSubtitle(subtitle, options)
+	default:
+		return nil, fmt.Errorf("Unknown index backend: %s", backend)
+	}
+}
+
+// defaultIndexBackend selects the index backend best suited for the given language.
+func defaultIndexBackend(language string) string {
+	switch analyzerLanguage(language) {
+	case "zh", "ja", "th", "lo", "km", "my":
+		return ngramBackend
+	default:
+		return bleveBackend
+	}
 }
 
-func NewIndexedSubtitle(subtitle *SubtitleFile) (IndexedSubtitle, error) {
-	// TODO: Tune the IndexMapping as needed
-	index, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+func newBleveIndexedSubtitle(subtitle *SubtitleFile, options IndexOptions) (IndexedSubtitle, error) {
+	indexMapping, err := newEntryMapping(options.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	ads := options.Ads.Ads(subtitle)
+
+	var index bleve.Index
+	populated := false
+	if options.CacheDir == "" {
+		index, err = bleve.NewMemOnly(indexMapping)
+	} else {
+		index, populated, err = openPersistentIndex(options.CacheDir, options.Source, subtitle, options.Language, ads, indexMapping)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	bis := &bleveIndexedSubtitle{
-		subtitle: subtitle,
-		index:    index,
+		subtitle:  subtitle,
+		index:     index,
+		fuzziness: options.Fuzziness,
+	}
+
+	if populated {
+		return bis, nil
 	}
 
-	err = bis.initialize()
+	err = bis.initialize(ads)
+	if err == nil && options.CacheDir != "" {
+		err = markPopulated(index)
+	}
 	if err != nil {
+		index.Close()
 		return nil, err
 	}
 
 	return bis, nil
 }
 
+// indexedEntry is the document indexed for each subtitle entry,
+// with its start and end times given in seconds.
+type indexedEntry struct {
+	Text  string  `json:"text"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// newEntryMapping creates an index mapping for indexedEntry documents,
+// with their text analyzed according to the given language.
+func newEntryMapping(language string) (mapping.IndexMapping, error) {
+	indexMapping, err := newIndexMapping(language)
+	if err != nil {
+		return nil, err
+	}
+
+	timeMapping := bleve.NewNumericFieldMapping()
+	timeMapping.IncludeInAll = false
+
+	entryMapping := bleve.NewDocumentStaticMapping()
+	entryMapping.AddFieldMappingsAt("text", bleve.NewTextFieldMapping())
+	entryMapping.AddFieldMappingsAt("start", timeMapping)
+	entryMapping.AddFieldMappingsAt("end", timeMapping)
+
+	indexMapping.DefaultMapping = entryMapping
+	return indexMapping, nil
+}
+
 type bleveIndexedSubtitle struct {
-	subtitle *SubtitleFile
-	index    bleve.Index
+	subtitle  *SubtitleFile
+	index     bleve.Index
+	fuzziness int
 }
 
-func (bis *bleveIndexedSubtitle) initialize() error {
+// initialize indexes the entries of the subtitle file, except for the ones flagged by ads, if not nil.
+func (bis *bleveIndexedSubtitle) initialize(ads []bool) error {
+	batch := bis.index.NewBatch()
 	for i, entry := range bis.subtitle.Entries {
 		docId := strconv.Itoa(i)
-		docContent := strings.Join(entry.Text, " ")
-		err := bis.index.Index(docId, docContent)
+		docContent := matchText(entry.Text)
+		if docContent == "" || (ads != nil && ads[i]) {
+			continue
+		}
+
+		err := batch.Index(docId, &indexedEntry{
+			Text:  docContent,
+			Start: entry.Start.Seconds(),
+			End:   entry.End.Seconds(),
+		})
 		if err != nil {
 			return err
 		}
+
+		if batch.Size() >= indexBatchSize {
+			err = bis.index.Batch(batch)
+			if err != nil {
+				return err
+			}
+			batch.Reset()
+		}
 	}
 
-	return nil
+	return bis.index.Batch(batch)
 }
 
 func (bis *bleveIndexedSubtitle) Search(text string) (*SubtitleEntry, error) {
-	q := query.NewQueryStringQuery(text)
-	req := bleve.NewSearchRequestOptions(q, 1, 0, false)
+	results, err := bis.SearchCandidates(text, 1)
+	if err == ErrNoHits || err == ErrBelowThreshold {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results[0].Entry, nil
+}
+
+func (bis *bleveIndexedSubtitle) SearchCandidates(text string, k int) ([]*SearchResult, error) {
+	text = matchText([]string{text})
+	if text == "" {
+		return nil, ErrNoHits
+	}
+
+	return bis.search(bis.textQuery(text), k, nil)
+}
+
+func (bis *bleveIndexedSubtitle) SearchNear(text string, k int, at, radius time.Duration) ([]*SearchResult, error) {
+	text = matchText([]string{text})
+	if text == "" {
+		return nil, ErrNoHits
+	}
+
+	// Exclude entries starting after the window, or ending before it.
+	from, to := (at - radius).Seconds(), (at + radius).Seconds()
+	startsAfter := query.NewNumericRangeQuery(&to, nil)
+	startsAfter.SetField("start")
+	endsBefore := query.NewNumericRangeQuery(nil, &from)
+	endsBefore.SetField("end")
+
+	q := query.NewBooleanQuery(
+		[]query.Query{bis.textQuery(text)},
+		nil,
+		[]query.Query{startsAfter, endsBefore})
+
+	return bis.search(q, k, func(entry *SubtitleEntry) float64 {
+		return proximity(entry, at, radius)
+	})
+}
+
+func (bis *bleveIndexedSubtitle) Close() error {
+	return bis.index.Close()
+}
+
+// textQuery creates a query matching entries containing any of the terms of the given text.
+// Unlike a query string query, the text is analyzed as is, with no query syntax.
+func (bis *bleveIndexedSubtitle) textQuery(text string) query.Query {
+	q := query.NewMatchQuery(text)
+	q.SetField("text")
+	q.SetFuzziness(bis.fuzziness)
+	return q
+}
+
+// search runs the given query, returning up to k results as described by SearchCandidates.
+// If boost is not nil, result scores are multiplied by the boost of their entry, and results
+// are ranked accordingly. The minHitScore threshold applies to the scores before boosting.
+func (bis *bleveIndexedSubtitle) search(q query.Query, k int, boost func(entry *SubtitleEntry) float64) ([]*SearchResult, error) {
+	size := k
+	if boost != nil {
+		// Boosting may reorder results, so consider more of them.
+		size = k * boostedSearchFactor
+	}
+
+	req := bleve.NewSearchRequestOptions(q, size, 0, false)
+	req.IncludeLocations = true
 
 	res, err := bis.index.Search(req)
 	if err != nil {
@@ -64,21 +325,106 @@ func (bis *bleveIndexedSubtitle) Search(text string) (*SubtitleEntry, error) {
 	}
 
 	if len(res.Hits) < 1 {
-		// TODO: should that be an error?
-		return nil, nil
+		return nil, ErrNoHits
 	}
 
-	hit := res.Hits[0]
+	results := make([]*SearchResult, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		i, err := strconv.Atoi(hit.ID)
+		if err != nil {
+			return nil, err
+		}
 
-	if hit.Score < minHitScore {
-		// TODO: should that be an error?
-		return nil, nil
+		result := &SearchResult{
+			Entry:        bis.subtitle.Entries[i],
+			Score:        hit.Score,
+			MatchedTerms: matchedTerms(hit),
+			rawScore:     hit.Score,
+		}
+		if boost != nil {
+			result.Score *= boost(result.Entry)
+		}
+
+		results = append(results, result)
 	}
 
-	i, err := strconv.Atoi(hit.ID)
-	if err != nil {
-		return nil, err
+	return rankResults(results, minHitScore, k)
+}
+
+// rankResults orders the given results by descending score, keeps the top k, and normalizes
+// their scores. Results whose raw score is below minScore are dropped, unless all are, in which
+// case they are returned along with ErrBelowThreshold.
+func rankResults(results []*SearchResult, minScore float64, k int) ([]*SearchResult, error) {
+	if len(results) == 0 {
+		return nil, ErrNoHits
+	}
+
+	aboveThreshold := make([]*SearchResult, 0, len(results))
+	for _, result := range results {
+		if result.rawScore >= minScore {
+			aboveThreshold = append(aboveThreshold, result)
+		}
+	}
+
+	if len(aboveThreshold) > 0 {
+		results = aboveThreshold
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	for _, result := range results {
+		result.NormalizedScore = result.Score / results[0].Score
+	}
+
+	if len(aboveThreshold) == 0 {
+		return results, ErrBelowThreshold
 	}
 
-	return bis.subtitle.Entries[i], nil
+	return results, nil
+}
+
+// proximity computes the SearchNear boost factor of the given entry, for the time window
+// [at-radius, at+radius]. Entries within an empty window aren't boosted.
+func proximity(entry *SubtitleEntry, at, radius time.Duration) float64 {
+	if radius <= 0 {
+		return 1
+	}
+
+	distance := (entry.Start+entry.End)/2 - at
+	if distance < 0 {
+		distance = -distance
+	}
+	if distance > radius {
+		distance = radius
+	}
+
+	return 1 - proximityBoost*float64(distance)/float64(radius)
+}
+
+// matchedTerms collects the terms found in the given hit, across all fields.
+func matchedTerms(hit *search.DocumentMatch) []string {
+	terms := make([]string, 0, 4)
+	seen := make(map[string]bool)
+	for _, termLocations := range hit.Locations {
+		for term := range termLocations {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+
+	sort.Strings(terms)
+	return terms
+}
+
+// matchText converts the given subtitle lines into the text used for matching,
+// with hearing-impaired annotations removed.
+func matchText(lines []string) string {
+	return strings.Join(stripSDHLines(lines), " ")
 }
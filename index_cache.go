@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/index/upsidedown"
+	"github.com/blevesearch/bleve/mapping"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// indexFormatVersion identifies the way subtitle entries are indexed. It must be changed whenever
+	// the indexed documents or the index mapping change, to invalidate existing persistent indexes.
+	indexFormatVersion = "3"
+
+	// indexLockTimeout is how long to wait for a persistent index used by another process.
+	indexLockTimeout = 10 * time.Second
+
+	// maxIndexAge is the duration after which unused persistent indexes are pruned.
+	maxIndexAge = 30 * 24 * time.Hour
+
+	// indexExtension is the extension of persistent index directories.
+	indexExtension = ".bleve"
+)
+
+var (
+	// indexVersionKey is the internal key holding the format version of a persistent index.
+	// It is only set once the index is fully populated.
+	indexVersionKey = []byte("subsyncer_index_version")
+)
+
+// openPersistentIndex opens the persistent index of the given subtitle file within cacheDir, using
+// the bbolt store. Indexes are keyed by a hash of the subtitle content and language, and of the
+// entries left out as ads, if not nil, so that a modified subtitle file gets a new index. If the
+// index doesn't exist, or is stale or incomplete, a new empty index is created, and the indexes it
+// supersedes are pruned, as described by pruneIndexes. The source identifies the subtitle file,
+// e.g. by its path, if known. The returned flag indicates whether the index is already populated.
+// If the index is used by another process for longer than indexLockTimeout, an error is returned.
+func openPersistentIndex(cacheDir, source string, subtitle *SubtitleFile, language string, ads []bool, indexMapping mapping.IndexMapping) (bleve.Index, bool, error) {
+	prefix := indexSourcePrefix(source, language)
+	path := filepath.Join(cacheDir, prefix+subtitleHash(subtitle, language, ads)+indexExtension)
+	storeConfig := map[string]interface{}{"timeout": indexLockTimeout}
+
+	if _, err := os.Stat(path); err == nil {
+		index, err := bleve.OpenUsing(path, storeConfig)
+		if err == bolt.ErrTimeout {
+			return nil, false, lockTimeoutError(path)
+		}
+		if err == nil {
+			version, err := index.GetInternal(indexVersionKey)
+			if err == nil && string(version) == indexFormatVersion {
+				// Keep track of the last use of the index, for pruning
+				now := time.Now()
+				os.Chtimes(path, now, now)
+				return index, true, nil
+			}
+			index.Close()
+		}
+
+		// Stale or incomplete index, rebuild it
+		err = os.RemoveAll(path)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	err := os.MkdirAll(cacheDir, 0755)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = pruneIndexes(cacheDir, prefix, path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	index, err := bleve.NewUsing(path, indexMapping, upsidedown.Name, bboltStoreName, storeConfig)
+	if err == bolt.ErrTimeout {
+		return nil, false, lockTimeoutError(path)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return index, false, nil
+}
+
+// indexSourcePrefix returns the prefix of the names of the persistent indexes of the given source
+// subtitle file in the given language, or an empty prefix if the source is unknown.
+func indexSourcePrefix(source, language string) string {
+	if source == "" {
+		return ""
+	}
+
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+
+	hash := sha256.Sum256([]byte(source + "\n" + analyzerLanguage(language)))
+	return hex.EncodeToString(hash[:4]) + "-"
+}
+
+// pruneIndexes removes the persistent indexes within cacheDir superseded by the index at the given
+// path: the other indexes of the same source subtitle file, given by their name prefix if not empty,
+// and the indexes which weren't used for maxIndexAge.
+func pruneIndexes(cacheDir, prefix, path string) error {
+	indexes, err := filepath.Glob(filepath.Join(cacheDir, "*"+indexExtension))
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if index == path {
+			continue
+		}
+
+		superseded := prefix != "" && strings.HasPrefix(filepath.Base(index), prefix)
+		if info, err := os.Stat(index); err == nil && time.Since(info.ModTime()) > maxIndexAge {
+			superseded = true
+		}
+
+		if superseded {
+			err = os.RemoveAll(index)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// defaultIndexCacheDir returns the default directory of persistent indexes managed by the cache
+// command, within the user cache directory.
+func defaultIndexCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "subsyncer", "indexes")
+}
+
+// IndexCacheStats returns the number of persistent indexes within cacheDir, and their total size in bytes.
+func IndexCacheStats(cacheDir string) (int, int64, error) {
+	indexes, err := filepath.Glob(filepath.Join(cacheDir, "*"+indexExtension))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size := int64(0)
+	for _, index := range indexes {
+		err = filepath.Walk(index, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(indexes), size, nil
+}
+
+// ClearIndexCache removes all persistent indexes within cacheDir.
+func ClearIndexCache(cacheDir string) error {
+	indexes, err := filepath.Glob(filepath.Join(cacheDir, "*"+indexExtension))
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		err = os.RemoveAll(index)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockTimeoutError returns the error of failing to open the persistent index at the given path,
+// as it's used by another process.
+func lockTimeoutError(path string) error {
+	return fmt.Errorf("Timed out waiting for the index %s, used by another subsyncer process", path)
+}
+
+// markPopulated marks the given persistent index as fully populated, and up to date.
+func markPopulated(index bleve.Index) error {
+	return index.SetInternal(indexVersionKey, []byte(indexFormatVersion))
+}
+
+// subtitleHash computes a hash of the content of the given subtitle file, its language, and the
+// entries flagged as ads, if not nil.
+func subtitleHash(subtitle *SubtitleFile, language string, ads []bool) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n", indexFormatVersion, analyzerLanguage(language))
+	for i, entry := range subtitle.Entries {
+		fmt.Fprintf(hash, "%d\n%d\n%d\n%s\n", entry.Index, entry.Start, entry.End, strings.Join(entry.Text, "\n"))
+		if ads != nil && ads[i] {
+			fmt.Fprintf(hash, "ad\n")
+		}
+		fmt.Fprintf(hash, "\n")
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexedSubtitlePersistent(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "subsyncer-index")
+	if err != nil {
+		t.Fatalf("Failed to create cache directory: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	options := IndexOptions{Language: "en", CacheDir: cacheDir}
+
+	// Build the index, and then reuse it
+	for i := 0; i < 2; i++ {
+		indexedSub, err := NewIndexedSubtitle(testSubtitle, options)
+		if err != nil {
+			t.Fatalf("Expected no error to occur while indexing subtitle (run %d), got error: %v", i, err)
+		}
+
+		resEntry, err := indexedSub.Search("Something horrible happend")
+		if err != nil {
+			t.Fatalf("Got error while searching (run %d): %v", i, err)
+		}
+
+		if resEntry == nil || resEntry.Index != 2 {
+			t.Errorf("Expected entry 2 to be found (run %d), got %v", i, resEntry)
+		}
+
+		err = indexedSub.Close()
+		if err != nil {
+			t.Fatalf("Got error while closing index (run %d): %v", i, err)
+		}
+	}
+
+	indexes, _ := filepath.Glob(filepath.Join(cacheDir, "*.bleve"))
+	if len(indexes) != 1 {
+		t.Fatalf("Expected a single persistent index, got %d", len(indexes))
+	}
+
+	indexMapping, err := newEntryMapping("en")
+	if err != nil {
+		t.Fatalf("Failed to create index mapping: %v", err)
+	}
+
+	index, populated, err := openPersistentIndex(cacheDir, "", testSubtitle, "en", nil, indexMapping)
+	if err != nil {
+		t.Fatalf("Got error while opening persistent index: %v", err)
+	}
+
+	if !populated {
+		t.Errorf("Expected persistent index to be reused")
+	}
+
+	// Invalidate the index, as if it was created by another version
+	index.SetInternal(indexVersionKey, []byte("0"))
+	index.Close()
+
+	index, populated, err = openPersistentIndex(cacheDir, "", testSubtitle, "en", nil, indexMapping)
+	if err != nil {
+		t.Fatalf("Got error while opening persistent index: %v", err)
+	}
+	index.Close()
+
+	if populated {
+		t.Errorf("Expected stale persistent index to be rebuilt")
+	}
+}
+
+func TestSubtitleHash(t *testing.T) {
+	modified := &SubtitleFile{
+		Entries: append([]*SubtitleEntry{}, testSubtitle.Entries...),
+	}
+	modified.Entries[2] = &SubtitleEntry{
+		Index: 3,
+		Start: testSubtitle.Entries[2].Start,
+		End:   testSubtitle.Entries[2].End,
+		Text:  []string{"And they all lived", "happily ever after!"},
+	}
+
+	hash := subtitleHash(testSubtitle, "en", nil)
+	if hash != subtitleHash(testSubtitle, "eng", nil) {
+		t.Errorf("Expected the hash to be the same for equivalent language codes")
+	}
+
+	if hash == subtitleHash(testSubtitle, "fr", nil) {
+		t.Errorf("Expected the hash to differ for different languages")
+	}
+
+	if hash == subtitleHash(modified, "en", nil) {
+		t.Errorf("Expected the hash to differ for different subtitle content")
+	}
+}
+
+func TestPruneIndexes(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "subsyncer-index")
+	if err != nil {
+		t.Fatalf("Failed to create cache directory: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	modified := &SubtitleFile{
+		Entries: append([]*SubtitleEntry{}, testSubtitle.Entries[:2]...),
+	}
+
+	index := func(subtitle *SubtitleFile, source string) {
+		indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en", CacheDir: cacheDir, Source: source})
+		if err != nil {
+			t.Fatalf("Expected no error to occur while indexing %s, got error: %v", source, err)
+		}
+		indexedSub.Close()
+	}
+
+	expectIndexes := func(expected int, when string) {
+		indexes, size, err := IndexCacheStats(cacheDir)
+		if err != nil {
+			t.Fatalf("Got error while computing index cache stats: %v", err)
+		}
+		if indexes != expected || (indexes > 0 && size == 0) {
+			t.Errorf("Expected %d persistent indexes %s, got %d of %d bytes", expected, when, indexes, size)
+		}
+	}
+
+	index(testSubtitle, "movie.en.srt")
+	index(testSubtitle, "other.en.srt")
+	expectIndexes(2, "of different subtitle files")
+
+	index(modified, "movie.en.srt")
+	expectIndexes(2, "once the index of a modified subtitle file supersedes its previous index")
+
+	// Indexes unused for long are pruned
+	old := time.Now().Add(-maxIndexAge - time.Hour)
+	paths, _ := filepath.Glob(filepath.Join(cacheDir, "*.bleve"))
+	for _, path := range paths {
+		os.Chtimes(path, old, old)
+	}
+
+	index(testSubtitle, "")
+	expectIndexes(1, "once unused indexes expire")
+
+	err = ClearIndexCache(cacheDir)
+	if err != nil {
+		t.Fatalf("Got error while clearing the index cache: %v", err)
+	}
+	expectIndexes(0, "after clearing the index cache")
+}
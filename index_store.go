@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/registry"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// bboltStoreName is the name of the bleve KV store persisting indexes in bbolt files.
+	bboltStoreName = "subsyncer_bbolt"
+
+	// bboltStoreBucket is the bucket holding the index rows.
+	bboltStoreBucket = "bleve"
+)
+
+func init() {
+	registry.RegisterKVStore(bboltStoreName, newBBoltStore)
+}
+
+// bboltStore is a bleve KV store on top of a bbolt file. Unlike the boltdb store of bleve, opening
+// it waits for the file lock held by other processes for a limited time only, given by the
+// "timeout" config, failing with bolt.ErrTimeout once it expires.
+type bboltStore struct {
+	db *bolt.DB
+	mo store.MergeOperator
+}
+
+func newBBoltStore(mo store.MergeOperator, config map[string]interface{}) (store.KVStore, error) {
+	path, _ := config["path"].(string)
+	if path == "" {
+		return nil, os.ErrInvalid
+	}
+
+	// The config given on creation is persisted in the index metadata as JSON, turning durations
+	// into float64 nanoseconds
+	var timeout time.Duration
+	switch value := config["timeout"].(type) {
+	case time.Duration:
+		timeout = value
+	case float64:
+		timeout = time.Duration(value)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bboltStoreBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &bboltStore{db: db, mo: mo}, nil
+}
+
+func (s *bboltStore) Reader() (store.KVReader, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bboltReader{tx: tx, bucket: tx.Bucket([]byte(bboltStoreBucket))}, nil
+}
+
+func (s *bboltStore) Writer() (store.KVWriter, error) {
+	return &bboltWriter{store: s}, nil
+}
+
+func (s *bboltStore) Close() error {
+	return s.db.Close()
+}
+
+// bboltReader reads a bboltStore within a read-only transaction.
+type bboltReader struct {
+	tx     *bolt.Tx
+	bucket *bolt.Bucket
+}
+
+func (r *bboltReader) Get(key []byte) ([]byte, error) {
+	// Values are only valid during the transaction
+	value := r.bucket.Get(key)
+	if value == nil {
+		return nil, nil
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+func (r *bboltReader) MultiGet(keys [][]byte) ([][]byte, error) {
+	return store.MultiGet(r, keys)
+}
+
+func (r *bboltReader) PrefixIterator(prefix []byte) store.KVIterator {
+	it := &bboltIterator{cursor: r.bucket.Cursor(), prefix: prefix}
+	it.Seek(prefix)
+	return it
+}
+
+func (r *bboltReader) RangeIterator(start, end []byte) store.KVIterator {
+	it := &bboltIterator{cursor: r.bucket.Cursor(), start: start, end: end}
+	it.Seek(start)
+	return it
+}
+
+func (r *bboltReader) Close() error {
+	return r.tx.Rollback()
+}
+
+// bboltIterator iterates over the keys of a bboltStore with a given prefix,
+// or within the range [start, end).
+type bboltIterator struct {
+	cursor *bolt.Cursor
+	prefix []byte
+	start  []byte
+	end    []byte
+
+	key   []byte
+	value []byte
+	valid bool
+}
+
+func (it *bboltIterator) Seek(key []byte) {
+	if it.start != nil && bytes.Compare(key, it.start) < 0 {
+		key = it.start
+	}
+	if it.prefix != nil && !bytes.HasPrefix(key, it.prefix) {
+		if bytes.Compare(key, it.prefix) > 0 {
+			it.valid = false
+			return
+		}
+		key = it.prefix
+	}
+
+	it.key, it.value = it.cursor.Seek(key)
+	it.update()
+}
+
+func (it *bboltIterator) Next() {
+	it.key, it.value = it.cursor.Next()
+	it.update()
+}
+
+// update checks whether the current key is within the iterated keys.
+func (it *bboltIterator) update() {
+	switch {
+	case it.key == nil:
+		it.valid = false
+	case it.prefix != nil:
+		it.valid = bytes.HasPrefix(it.key, it.prefix)
+	case it.end != nil:
+		it.valid = bytes.Compare(it.key, it.end) < 0
+	default:
+		it.valid = true
+	}
+}
+
+func (it *bboltIterator) Key() []byte {
+	return it.key
+}
+
+func (it *bboltIterator) Value() []byte {
+	return it.value
+}
+
+func (it *bboltIterator) Valid() bool {
+	return it.valid
+}
+
+func (it *bboltIterator) Current() ([]byte, []byte, bool) {
+	return it.key, it.value, it.valid
+}
+
+func (it *bboltIterator) Close() error {
+	return nil
+}
+
+// bboltWriter writes emulated batches into a bboltStore, each within a single transaction.
+type bboltWriter struct {
+	store *bboltStore
+}
+
+func (w *bboltWriter) NewBatch() store.KVBatch {
+	return store.NewEmulatedBatch(w.store.mo)
+}
+
+func (w *bboltWriter) NewBatchEx(options store.KVBatchOptions) ([]byte, store.KVBatch, error) {
+	return make([]byte, options.TotalBytes), w.NewBatch(), nil
+}
+
+func (w *bboltWriter) ExecuteBatch(batch store.KVBatch) error {
+	emulated, ok := batch.(*store.EmulatedBatch)
+	if !ok {
+		return fmt.Errorf("Unexpected batch type: %T", batch)
+	}
+
+	return w.store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bboltStoreBucket))
+		for key, operands := range emulated.Merger.Merges {
+			merged, ok := w.store.mo.FullMerge([]byte(key), bucket.Get([]byte(key)), operands)
+			if !ok {
+				return fmt.Errorf("Failed to merge the index row %q", key)
+			}
+
+			err := bucket.Put([]byte(key), merged)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, op := range emulated.Ops {
+			var err error
+			if op.V != nil {
+				err = bucket.Put(op.K, op.V)
+			} else {
+				err = bucket.Delete(op.K)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (w *bboltWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/index/store/test"
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTestBBoltStore opens a bboltStore in a new temporary directory, returning it along with
+// a function closing and removing it.
+func openTestBBoltStore(t *testing.T, mo store.MergeOperator) (store.KVStore, func()) {
+	dir, err := ioutil.TempDir("", "subsyncer-store")
+	if err != nil {
+		t.Fatalf("Failed to create store directory: %v", err)
+	}
+
+	s, err := newBBoltStore(mo, map[string]interface{}{"path": filepath.Join(dir, "store")})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Expected no error to occur while opening the store, got error: %v", err)
+	}
+
+	return s, func() {
+		if err := s.Close(); err != nil {
+			t.Errorf("Got error while closing the store: %v", err)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// TestBBoltStore runs the conformance tests of bleve KV stores against bboltStore.
+func TestBBoltStore(t *testing.T) {
+	cases := []struct {
+		name string
+		mo   store.MergeOperator
+		test func(t *testing.T, s store.KVStore)
+	}{
+		{"KVCrud", nil, test.CommonTestKVCrud},
+		{"ReaderIsolation", nil, test.CommonTestReaderIsolation},
+		{"ReaderOwnsGetBytes", nil, test.CommonTestReaderOwnsGetBytes},
+		{"WriterOwnsBytes", nil, test.CommonTestWriterOwnsBytes},
+		{"PrefixIterator", nil, test.CommonTestPrefixIterator},
+		{"PrefixIteratorSeek", nil, test.CommonTestPrefixIteratorSeek},
+		{"RangeIterator", nil, test.CommonTestRangeIterator},
+		{"RangeIteratorSeek", nil, test.CommonTestRangeIteratorSeek},
+		{"Merge", &test.TestMergeCounter{}, test.CommonTestMerge},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, remove := openTestBBoltStore(t, c.mo)
+			defer remove()
+			c.test(t, s)
+		})
+	}
+}
+
+func TestBBoltStoreLockTimeout(t *testing.T) {
+	s, remove := openTestBBoltStore(t, nil)
+	defer remove()
+
+	// The lock is held per open file, so a second open within the process waits for it too
+	config := map[string]interface{}{
+		"path":    s.(*bboltStore).db.Path(),
+		"timeout": 100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := newBBoltStore(nil, config)
+	if err != bolt.ErrTimeout {
+		t.Errorf("Expected opening a store in use to time out, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected opening a store in use to give up after the timeout, took %v", elapsed)
+	}
+
+	// Timeouts persisted in the index metadata are decoded from JSON as float64 nanoseconds
+	config["timeout"] = float64(100 * time.Millisecond)
+	_, err = newBBoltStore(nil, config)
+	if err != bolt.ErrTimeout {
+		t.Errorf("Expected opening a store in use to time out with a JSON decoded timeout, got %v", err)
+	}
+}
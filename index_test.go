@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"strings"
 	"testing"
 )
@@ -29,7 +30,7 @@ var testSubtitle = &SubtitleFile{
 }
 
 func TestIndexedSubtitleSearchExact(t *testing.T) {
-	indexedSub, err := NewIndexedSubtitle(testSubtitle)
+	indexedSub, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en"})
 	if err != nil {
 		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
 	}
@@ -54,7 +55,7 @@ func TestIndexedSubtitleSearchExact(t *testing.T) {
 }
 
 func TestIndexedSubtitleSearchJoinedLines(t *testing.T) {
-	indexedSub, err := NewIndexedSubtitle(testSubtitle)
+	indexedSub, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en"})
 	if err != nil {
 		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
 	}
@@ -78,7 +79,7 @@ func TestIndexedSubtitleSearchJoinedLines(t *testing.T) {
 }
 
 func TestIndexedSubtitleSearchProximity(t *testing.T) {
-	indexedSub, err := NewIndexedSubtitle(testSubtitle)
+	indexedSub, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en"})
 	if err != nil {
 		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
 	}
@@ -112,7 +113,7 @@ func TestIndexedSubtitleSearchProximity(t *testing.T) {
 }
 
 func TestIndexedSubtitleSearchNoMatch(t *testing.T) {
-	indexedSub, err := NewIndexedSubtitle(testSubtitle)
+	indexedSub, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en"})
 	if err != nil {
 		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
 	}
@@ -164,3 +165,217 @@ func equalSubtitleEntries(e1, e2 *SubtitleEntry) bool {
 
 	return true
 }
+
+func TestIndexedSubtitleSearchCandidates(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Text: []string{"I love you."}},
+			{Index: 2, Text: []string{"Let's go home."}},
+			{Index: 3, Text: []string{"I love you too,", "but we have to go."}},
+			{Index: 4, Text: []string{"Once upon a time"}},
+		},
+	}
+
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	results, err := indexedSub.SearchCandidates("I love you", 3)
+	if err != nil {
+		t.Fatalf("Got error while searching candidates: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 candidates, got %d", len(results))
+	}
+
+	if results[0].Entry.Index != 1 || results[1].Entry.Index != 3 {
+		t.Errorf("Expected candidates to be entries 1 and 3, got %d and %d", results[0].Entry.Index, results[1].Entry.Index)
+	}
+
+	if results[0].NormalizedScore != 1 {
+		t.Errorf("Expected top candidate normalized score to be 1, got %v", results[0].NormalizedScore)
+	}
+
+	if results[1].Score >= results[0].Score || results[1].NormalizedScore >= 1 {
+		t.Errorf("Expected second candidate to score lower than the top one, got %v and %v", results[1].Score, results[0].Score)
+	}
+
+	if len(results[1].MatchedTerms) != 1 || results[1].MatchedTerms[0] != "love" {
+		t.Errorf("Expected matched terms of second candidate to be [love], got %v", results[1].MatchedTerms)
+	}
+}
+
+func TestIndexedSubtitleSearchCandidatesErrors(t *testing.T) {
+	indexedSub, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	_, err = indexedSub.SearchCandidates("Completely unrelated phrase", 3)
+	if err != ErrNoHits {
+		t.Errorf("Expected ErrNoHits, got %v", err)
+	}
+
+	results, err := indexedSub.SearchCandidates("Or some common ideas from time to time", 3)
+	if err != ErrBelowThreshold {
+		t.Errorf("Expected ErrBelowThreshold, got %v", err)
+	}
+
+	if len(results) == 0 {
+		t.Errorf("Expected low scoring candidates to be returned along with ErrBelowThreshold")
+	}
+}
+
+func TestIndexedSubtitleSearchNear(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("1m"), End: mustParseDuration("1m2s"), Text: []string{"Let's go!"}},
+			{Index: 2, Start: mustParseDuration("10m"), End: mustParseDuration("10m2s"), Text: []string{"Let's go!"}},
+			{Index: 3, Start: mustParseDuration("10m40s"), End: mustParseDuration("10m42s"), Text: []string{"Let's go!"}},
+			{Index: 4, Start: mustParseDuration("30m"), End: mustParseDuration("30m2s"), Text: []string{"Let's go!"}},
+		},
+	}
+
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	cases := []struct {
+		at      string
+		radius  string
+		indices []int
+	}{
+		{"10m10s", "1m", []int{2, 3}},
+		{"10m30s", "1m", []int{3, 2}},
+		{"1m", "30s", []int{1}},
+		{"20m", "1m", nil},
+		{"10m1s", "0s", []int{2}},
+	}
+
+	for i, c := range cases {
+		results, err := indexedSub.SearchNear("Let's go", 4, mustParseDuration(c.at), mustParseDuration(c.radius))
+		if len(c.indices) == 0 {
+			if err != ErrNoHits {
+				t.Errorf("Expected ErrNoHits (case %d), got %v", i, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Got error while searching (case %d): %v", i, err)
+		}
+
+		if len(results) != len(c.indices) {
+			t.Fatalf("Expected %d results (case %d), got %d", len(c.indices), i, len(results))
+		}
+
+		for j, index := range c.indices {
+			if results[j].Entry.Index != index {
+				t.Errorf("Expected result %d to be entry %d (case %d), got entry %d", j, index, i, results[j].Entry.Index)
+			}
+		}
+
+		for j, result := range results {
+			if math.IsNaN(result.Score) {
+				t.Errorf("Expected result %d to have a valid score (case %d), got %v", j, i, result.Score)
+			}
+		}
+	}
+}
+
+func TestIndexedSubtitleSearchQuerySyntax(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Text: []string{"- Who's knocking?", "- Me: your brother!"}},
+			{Index: 2, Text: []string{"+2 points for \"team\" ~ ^"}},
+			{Index: 3, Text: []string{"Once upon a time"}},
+		},
+	}
+
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	cases := []struct {
+		text  string
+		index int
+	}{
+		{"- Who's knocking?", 1},
+		{"- Me: your brother!", 1},
+		{"-brother +knocking", 1},
+		{"+2 points for \"team\" ~ ^", 2},
+		{"points~ team^2", 2},
+	}
+
+	for i, c := range cases {
+		resEntry, err := indexedSub.Search(c.text)
+		if err != nil {
+			t.Fatalf("Got error while searching (case %d): %v", i, err)
+		}
+
+		if resEntry == nil || resEntry.Index != c.index {
+			t.Errorf("Expected entry %d to be found (case %d), got %v", c.index, i, resEntry)
+		}
+	}
+}
+
+func TestIndexedSubtitleSearchFuzziness(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Text: []string{"Honour thy father"}},
+			{Index: 2, Text: []string{"Once upon a time"}},
+		},
+	}
+
+	for _, fuzziness := range []int{0, 1} {
+		indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en", Fuzziness: fuzziness})
+		if err != nil {
+			t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+		}
+
+		resEntry, err := indexedSub.Search("Honor")
+		if err != nil {
+			t.Fatalf("Got error while searching (fuzziness %d): %v", fuzziness, err)
+		}
+
+		found := resEntry != nil && resEntry.Index == 1
+		if fuzziness == 0 && found {
+			t.Errorf("Expected no entry to be found with no fuzziness, got entry %d", resEntry.Index)
+		}
+		if fuzziness > 0 && !found {
+			t.Errorf("Expected entry 1 to be found with fuzziness %d, got %v", fuzziness, resEntry)
+		}
+	}
+}
+
+func TestIndexedSubtitleAds(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: append([]*SubtitleEntry{
+			{Index: 0, Start: mustParseDuration("1s"), End: mustParseDuration("3s"), Text: []string{"Subtitles downloaded from www.example.com"}},
+		}, testSubtitle.Entries...),
+	}
+
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	resEntry, err := indexedSub.Search("downloaded subtitles")
+	if err != nil || resEntry != subtitle.Entries[0] {
+		t.Errorf("Expected all entries to be indexed without an ad detector, got %v (error: %v)", resEntry, err)
+	}
+
+	indexedSub, err = NewIndexedSubtitle(subtitle, IndexOptions{Language: "en", Ads: builtinAdDetector})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	resEntry, err = indexedSub.Search("downloaded subtitles")
+	if err == nil && resEntry != nil {
+		t.Errorf("Expected ads to be left out of the index, got %v", resEntry)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Language is a language, optionally in a given script and region, as given by a BCP 47 tag.
+type Language struct {
+	// Code is the ISO 639-1 code of the language, or its ISO 639-3 code if it has none.
+	Code string
+
+	// Script is the ISO 15924 code of the script, in title case, e.g. "Hant". Empty if unspecified.
+	Script string
+
+	// Region is the ISO 3166-1 code of the region, in upper case, e.g. "BR". Empty if unspecified.
+	Region string
+}
+
+// languageInfo holds the codes and name of a known language.
+type languageInfo struct {
+	iso6391  string
+	iso6392T string
+	iso6392B string
+	name     string
+}
+
+// knownLanguages holds the languages commonly found in subtitle files.
+// Languages with no ISO 639-1 code are identified by their ISO 639-3 code.
+var knownLanguages = []languageInfo{
+	{"ar", "ara", "ara", "Arabic"},
+	{"bg", "bul", "bul", "Bulgarian"},
+	{"bn", "ben", "ben", "Bengali"},
+	{"bs", "bos", "bos", "Bosnian"},
+	{"ca", "cat", "cat", "Catalan"},
+	{"ckb", "ckb", "ckb", "Central Kurdish"},
+	{"cs", "ces", "cze", "Czech"},
+	{"da", "dan", "dan", "Danish"},
+	{"de", "deu", "ger", "German"},
+	{"el", "ell", "gre", "Greek"},
+	{"en", "eng", "eng", "English"},
+	{"eo", "epo", "epo", "Esperanto"},
+	{"es", "spa", "spa", "Spanish"},
+	{"et", "est", "est", "Estonian"},
+	{"eu", "eus", "baq", "Basque"},
+	{"fa", "fas", "per", "Persian"},
+	{"fi", "fin", "fin", "Finnish"},
+	{"fr", "fra", "fre", "French"},
+	{"ga", "gle", "gle", "Irish"},
+	{"gl", "glg", "glg", "Galician"},
+	{"he", "heb", "heb", "Hebrew"},
+	{"hi", "hin", "hin", "Hindi"},
+	{"hr", "hrv", "hrv", "Croatian"},
+	{"hu", "hun", "hun", "Hungarian"},
+	{"hy", "hye", "arm", "Armenian"},
+	{"id", "ind", "ind", "Indonesian"},
+	{"is", "isl", "ice", "Icelandic"},
+	{"it", "ita", "ita", "Italian"},
+	{"ja", "jpn", "jpn", "Japanese"},
+	{"ka", "kat", "geo", "Georgian"},
+	{"kk", "kaz", "kaz", "Kazakh"},
+	{"ko", "kor", "kor", "Korean"},
+	{"lt", "lit", "lit", "Lithuanian"},
+	{"lv", "lav", "lav", "Latvian"},
+	{"mk", "mkd", "mac", "Macedonian"},
+	{"ml", "mal", "mal", "Malayalam"},
+	{"ms", "msa", "may", "Malay"},
+	{"nb", "nob", "nob", "Norwegian Bokmål"},
+	{"nl", "nld", "dut", "Dutch"},
+	{"nn", "nno", "nno", "Norwegian Nynorsk"},
+	{"no", "nor", "nor", "Norwegian"},
+	{"pl", "pol", "pol", "Polish"},
+	{"pt", "por", "por", "Portuguese"},
+	{"ro", "ron", "rum", "Romanian"},
+	{"ru", "rus", "rus", "Russian"},
+	{"sk", "slk", "slo", "Slovak"},
+	{"sl", "slv", "slv", "Slovenian"},
+	{"sq", "sqi", "alb", "Albanian"},
+	{"sr", "srp", "srp", "Serbian"},
+	{"sv", "swe", "swe", "Swedish"},
+	{"sw", "swa", "swa", "Swahili"},
+	{"ta", "tam", "tam", "Tamil"},
+	{"te", "tel", "tel", "Telugu"},
+	{"th", "tha", "tha", "Thai"},
+	{"tl", "tgl", "tgl", "Tagalog"},
+	{"tr", "tur", "tur", "Turkish"},
+	{"uk", "ukr", "ukr", "Ukrainian"},
+	{"ur", "urd", "urd", "Urdu"},
+	{"vi", "vie", "vie", "Vietnamese"},
+	{"yue", "yue", "yue", "Cantonese"},
+	{"zh", "zho", "chi", "Chinese"},
+}
+
+// languageAliases maps deprecated ISO 639-1 codes, ISO 639-3 codes of individual languages within
+// a macrolanguage, and codes used by subtitle sites, to the language tags they stand for.
+var languageAliases = map[string]string{
+	"iw":  "he",
+	"in":  "id",
+	"arb": "ar",
+	"cmn": "zh",
+	"pes": "fa",
+	"zsm": "ms",
+	"fil": "tl",
+	"pob": "pt-BR",
+	"zht": "zh-Hant",
+	"zhe": "zh-Hant",
+}
+
+var (
+	// languagesByCode maps the lower case ISO 639 codes and English names of the known languages to them.
+	languagesByCode = make(map[string]*languageInfo)
+)
+
+func init() {
+	for i := range knownLanguages {
+		info := &knownLanguages[i]
+		for _, key := range []string{info.iso6391, info.iso6392T, info.iso6392B, strings.ToLower(info.name)} {
+			languagesByCode[key] = info
+		}
+	}
+}
+
+// ParseLanguage parses the given language code, which may be an ISO 639-1, ISO 639-2 or ISO 639-3
+// code, a BCP 47 tag with script and region subtags, e.g. "pt-BR" or "zh-Hant", or the English name
+// of the language. Codes are case insensitive, and subtags may also be separated by underscores.
+func ParseLanguage(s string) (Language, error) {
+	subtags := strings.FieldsFunc(strings.TrimSpace(s), func(r rune) bool { return r == '-' || r == '_' })
+	if len(subtags) == 0 {
+		return Language{}, fmt.Errorf("No language specified")
+	}
+
+	primary := strings.ToLower(subtags[0])
+	if alias, ok := languageAliases[primary]; ok {
+		language, _ := ParseLanguage(alias)
+		return language.withSubtags(subtags[1:]), nil
+	}
+
+	if info, ok := languagesByCode[primary]; ok {
+		return Language{Code: info.iso6391}.withSubtags(subtags[1:]), nil
+	}
+
+	// English names may span several words, e.g. "Norwegian Bokmål"
+	if info, ok := languagesByCode[strings.ToLower(strings.Join(strings.Fields(s), " "))]; ok {
+		return Language{Code: info.iso6391}, nil
+	}
+
+	return Language{}, fmt.Errorf("Unknown language: %s", s)
+}
+
+// withSubtags returns the language with the script and region given by the given BCP 47 subtags,
+// ignoring variant and extension subtags.
+func (l Language) withSubtags(subtags []string) Language {
+	for _, subtag := range subtags {
+		switch {
+		case len(subtag) == 4 && isLetters(subtag) && l.Script == "":
+			l.Script = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		case (len(subtag) == 2 && isLetters(subtag) || len(subtag) == 3 && isDigits(subtag)) && l.Region == "":
+			l.Region = strings.ToUpper(subtag)
+		}
+	}
+	return l
+}
+
+// LanguageFromFilename parses the language tag of the given subtitle file name, given as the
+// extension preceding the subtitle format extension, e.g. "Movie.pt-BR.srt" or "Movie.heb.srt".
+func LanguageFromFilename(path string) (Language, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	tag := filepath.Ext(name)
+	if len(tag) < 3 {
+		return Language{}, false
+	}
+
+	language, err := ParseLanguage(tag[1:])
+	if err != nil {
+		return Language{}, false
+	}
+
+	return language, true
+}
+
+// Tag returns the BCP 47 tag of the language, e.g. "pt-BR".
+func (l Language) Tag() string {
+	tag := l.Code
+	if l.Script != "" {
+		tag += "-" + l.Script
+	}
+	if l.Region != "" {
+		tag += "-" + l.Region
+	}
+	return tag
+}
+
+func (l Language) String() string {
+	return l.Tag()
+}
+
+// ISO6392 returns the ISO 639-2 (terminology) code of the language, e.g. "heb".
+func (l Language) ISO6392() string {
+	if info, ok := languagesByCode[l.Code]; ok {
+		return info.iso6392T
+	}
+	return l.Code
+}
+
+// Name returns the English name of the language.
+func (l Language) Name() string {
+	if info, ok := languagesByCode[l.Code]; ok {
+		return info.name
+	}
+	return l.Code
+}
+
+// TraditionalChinese checks whether the language is Chinese written in traditional characters,
+// either explicitly or as implied by its region.
+func (l Language) TraditionalChinese() bool {
+	if l.Code != "zh" {
+		return false
+	}
+	if l.Script != "" {
+		return l.Script == "Hant"
+	}
+	switch l.Region {
+	case "TW", "HK", "MO":
+		return true
+	default:
+		return false
+	}
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// languageNgramSpace is the assumed number of distinct n-grams of each length in a language,
+	// and languageNgramSmoothing the count added to each, so that n-grams missing from the sample
+	// of a language are not impossible.
+	languageNgramSpace     = 1000
+	languageNgramSmoothing = 0.1
+
+	// maxLanguageNgram is the length of the longest n-grams in a language profile.
+	maxLanguageNgram = 3
+
+	// minDetectionLetters is the minimal number of letters required to detect a language.
+	minDetectionLetters = 20
+
+	// maxDetectionChars is the number of characters of a subtitle file used to detect its language.
+	maxDetectionChars = 50000
+
+	// maxRemoteDetectionChars is the number of characters sent to a translation service
+	// to cross-check the detected language.
+	maxRemoteDetectionChars = 2000
+
+	// minDetectionConfidence is the minimal confidence of a detected language which is trusted
+	// over the declared language of a subtitle file, or used if no language is declared.
+	minDetectionConfidence = 0.15
+
+	// minProfileCoverage is the minimal fraction of the trigrams of text found in the sample of the
+	// language it's detected as by its n-grams. Text in a language without a profile is still closest
+	// to some profile, typically of a related language, but shares fewer trigrams with its sample.
+	minProfileCoverage = 0.4
+)
+
+// languageSamples holds sample text of each language identified by its n-grams, rather than by
+// its script. The samples are of everyday dialogue, as found in subtitles.
+var languageSamples = map[string]string{
+	"bg": "Не знам за какво говориш. Къде беше цялата нощ? Трябва да се махнем оттук, преди да се върнат. " +
+		"Благодаря ти за всичко, което направи за мен. Сигурен ли си, че това е правилният път? " +
+		"Не е моя вина, той вече беше там, когато пристигнах. Хайде, да се прибираме, става късно. Какво ти каза тя? " +
+		"Какво правиш тук? Търсих те навсякъде. Защо не ми се обади? Трябва да говоря с теб за нещо важно. Полицията ще бъде тук след пет минути. Тя е моя дъщеря и ще направя всичко, за да я защитя. " +
+		"Той каза, че няма да се върне преди вечерта. Може ли да останем тук още малко? Искам да знаеш истината. Никога повече не прави така, става ли? Много съжалявам, че стана така.",
+	"cs": "Nevím, o čem mluvíš. Kde jsi byl celou noc? Musíme odsud vypadnout, než se vrátí. " +
+		"Děkuji za všechno, co jsi pro mě udělal. Jsi si jistý, že je to správná cesta? " +
+		"Není to moje vina, už tam byl, když jsem přišel. Pojď, půjdeme domů, už je pozdě. Co ti řekla? " +
+		"Co tady děláš? Všude jsem tě hledal. Proč jsi mi nezavolal? Potřebuju s tebou mluvit o něčem důležitém. Policie tu bude za pět minut. Je to moje dcera a udělám cokoliv, abych ji ochránil.",
+	"de": "Ich weiß nicht, wovon du redest. Wo warst du die ganze Nacht? Wir müssen hier weg, bevor sie zurückkommen. " +
+		"Danke für alles, was du für mich getan hast. Bist du sicher, dass das der richtige Weg ist? " +
+		"Es ist nicht meine Schuld, er war schon da, als ich ankam. Komm, lass uns nach Hause gehen, es wird spät. Was hat sie dir gesagt? " +
+		"Was machst du hier? Ich habe dich überall gesucht. Warum hast du mich nicht angerufen? Ich muss mit dir über etwas Wichtiges reden. Die Polizei wird in fünf Minuten hier sein. Sie ist meine Tochter, und ich werde alles tun, um sie zu beschützen.",
+	"en": "I don't know what you're talking about. Where have you been all night? We have to get out of here before they come back. " +
+		"Thank you for everything you did for me. Are you sure this is the right way? " +
+		"It's not my fault, he was already there when I arrived. Come on, let's go home, it's getting late. What did she say to you? " +
+		"What are you doing here? I was looking for you everywhere. Why didn't you call me? I need to talk to you about something important. The police are going to be here in five minutes. She's my daughter, and I'll do anything to protect her.",
+	"es": "No sé de qué estás hablando. ¿Dónde has estado toda la noche? Tenemos que salir de aquí antes de que vuelvan. " +
+		"Gracias por todo lo que hiciste por mí. ¿Estás seguro de que este es el camino correcto? " +
+		"No es mi culpa, él ya estaba allí cuando llegué. Vamos, volvamos a casa, se está haciendo tarde. ¿Qué te dijo ella? " +
+		"¿Qué haces aquí? Te estaba buscando por todas partes. ¿Por qué no me llamaste? Necesito hablar contigo de algo importante. La policía va a llegar en cinco minutos. Es mi hija, y haré cualquier cosa para protegerla.",
+	"fr": "Je ne sais pas de quoi tu parles. Où étais-tu toute la nuit ? Nous devons partir d'ici avant qu'ils reviennent. " +
+		"Merci pour tout ce que tu as fait pour moi. Tu es sûr que c'est le bon chemin ? " +
+		"Ce n'est pas ma faute, il était déjà là quand je suis arrivé. Allez, rentrons à la maison, il se fait tard. Qu'est-ce qu'elle t'a dit ? " +
+		"Qu'est-ce que tu fais ici ? Je te cherchais partout. Pourquoi tu ne m'as pas appelé ? J'ai besoin de te parler de quelque chose d'important. La police sera là dans cinq minutes. C'est ma fille, et je ferai n'importe quoi pour la protéger.",
+	"id": "Aku tidak tahu apa yang kamu bicarakan. Di mana kamu sepanjang malam? Kita harus keluar dari sini sebelum mereka kembali. " +
+		"Terima kasih untuk semua yang kamu lakukan untukku. Apa kamu yakin ini jalan yang benar? " +
+		"Ini bukan salahku, dia sudah ada di sana waktu aku datang. Ayo, kita pulang, sudah larut. Apa yang dia katakan padamu? " +
+		"Apa yang kamu lakukan di sini? Aku mencarimu ke mana-mana. Kenapa kamu tidak meneleponku? Aku perlu bicara denganmu tentang sesuatu yang penting. Polisi akan tiba dalam lima menit. Dia putriku, dan aku akan melakukan apa saja untuk melindunginya.",
+	"it": "Non so di cosa stai parlando. Dove sei stato tutta la notte? Dobbiamo andarcene da qui prima che tornino. " +
+		"Grazie per tutto quello che hai fatto per me. Sei sicuro che questa sia la strada giusta? " +
+		"Non è colpa mia, lui era già lì quando sono arrivato. Dai, torniamo a casa, si sta facendo tardi. Che cosa ti ha detto? " +
+		"Che ci fai qui? Ti stavo cercando dappertutto. Perché non mi hai chiamato? Ho bisogno di parlarti di una cosa importante. La polizia sarà qui tra cinque minuti. È mia figlia, e farò qualsiasi cosa per proteggerla.",
+	"nl": "Ik weet niet waar je het over hebt. Waar ben je de hele nacht geweest? We moeten hier weg voordat ze terugkomen. " +
+		"Bedankt voor alles wat je voor me hebt gedaan. Weet je zeker dat dit de goede weg is? " +
+		"Het is niet mijn schuld, hij was er al toen ik aankwam. Kom op, laten we naar huis gaan, het wordt laat. Wat heeft ze tegen je gezegd? " +
+		"Wat doe je hier? Ik heb je overal gezocht. Waarom heb je me niet gebeld? Ik moet met je praten over iets belangrijks. De politie is hier over vijf minuten. Ze is mijn dochter, en ik doe alles om haar te beschermen.",
+	"pl": "Nie wiem, o czym mówisz. Gdzie byłeś całą noc? Musimy się stąd wydostać, zanim wrócą. " +
+		"Dziękuję za wszystko, co dla mnie zrobiłeś. Jesteś pewien, że to właściwa droga? " +
+		"To nie moja wina, on już tam był, kiedy przyszedłem. Chodź, wracajmy do domu, robi się późno. Co ona ci powiedziała? " +
+		"Co ty tu robisz? Szukałem cię wszędzie. Dlaczego do mnie nie zadzwoniłeś? Muszę z tobą porozmawiać o czymś ważnym. Policja będzie tu za pięć minut. To moja córka i zrobię wszystko, żeby ją chronić.",
+	"pt": "Eu não sei do que você está falando. Onde você esteve a noite toda? Temos que sair daqui antes que eles voltem. " +
+		"Obrigado por tudo o que você fez por mim. Tem certeza de que este é o caminho certo? " +
+		"Não é culpa minha, ele já estava lá quando eu cheguei. Vamos, vamos para casa, está ficando tarde. O que ela disse para você? " +
+		"O que você está fazendo aqui? Eu estava te procurando por toda parte. Por que você não me ligou? Preciso falar com você sobre uma coisa importante. A polícia vai chegar em cinco minutos. Ela é minha filha, e eu faço qualquer coisa para protegê-la.",
+	"ro": "Nu știu despre ce vorbești. Unde ai fost toată noaptea? Trebuie să plecăm de aici înainte să se întoarcă. " +
+		"Mulțumesc pentru tot ce ai făcut pentru mine. Ești sigur că acesta este drumul cel bun? " +
+		"Nu e vina mea, el era deja acolo când am ajuns. Haide, hai să mergem acasă, se face târziu. Ce ți-a spus ea? " +
+		"Ce faci aici? Te-am căutat peste tot. De ce nu m-ai sunat? Trebuie să vorbesc cu tine despre ceva important. Poliția va fi aici în cinci minute. Este fiica mea și voi face orice ca să o protejez.",
+	"ru": "Я не знаю, о чём ты говоришь. Где ты был всю ночь? Нам нужно уйти отсюда, пока они не вернулись. " +
+		"Спасибо за всё, что ты для меня сделал. Ты уверен, что это правильная дорога? " +
+		"Это не моя вина, он уже был там, когда я пришёл. Давай, пойдём домой, уже поздно. Что она тебе сказала? " +
+		"Что ты здесь делаешь? Я тебя везде искал. Почему ты мне не позвонил? Мне нужно поговорить с тобой о чём-то важном. Полиция будет здесь через пять минут. Она моя дочь, и я сделаю всё, чтобы её защитить. " +
+		"Он сказал, что его не будет дома до вечера. Мы можем остаться здесь ещё немного? Я хочу, чтобы ты знал правду. Никогда больше так не делай, хорошо? Мне очень жаль, что так получилось.",
+	"sv": "Jag vet inte vad du pratar om. Var har du varit hela natten? Vi måste komma härifrån innan de kommer tillbaka. " +
+		"Tack för allt du har gjort för mig. Är du säker på att det här är rätt väg? " +
+		"Det är inte mitt fel, han var redan där när jag kom. Kom igen, vi går hem, det börjar bli sent. Vad sa hon till dig? " +
+		"Vad gör du här? Jag har letat efter dig överallt. Varför ringde du inte? Jag måste prata med dig om något viktigt. Polisen kommer hit om fem minuter. Hon är min dotter, och jag gör vad som helst för att skydda henne.",
+	"tr": "Neden bahsettiğini bilmiyorum. Bütün gece neredeydin? Onlar geri gelmeden buradan çıkmamız lazım. " +
+		"Benim için yaptığın her şey için teşekkür ederim. Bunun doğru yol olduğundan emin misin? " +
+		"Benim suçum değil, ben geldiğimde o zaten oradaydı. Hadi, eve gidelim, geç oluyor. Sana ne söyledi? " +
+		"Burada ne yapıyorsun? Seni her yerde aradım. Neden beni aramadın? Seninle önemli bir şey hakkında konuşmam gerek. Polis beş dakika içinde burada olacak. O benim kızım ve onu korumak için her şeyi yaparım.",
+	"uk": "Я не знаю, про що ти говориш. Де ти був усю ніч? Нам треба піти звідси, поки вони не повернулися. " +
+		"Дякую за все, що ти для мене зробив. Ти впевнений, що це правильна дорога? " +
+		"Це не моя провина, він уже був там, коли я прийшов. Давай, ходімо додому, вже пізно. Що вона тобі сказала? " +
+		"Що ти тут робиш? Я шукав тебе всюди. Чому ти мені не подзвонив? Мені треба поговорити з тобою про щось важливе. Поліція буде тут за п'ять хвилин. Вона моя донька, і я зроблю все, щоб її захистити. " +
+		"Він сказав, що його не буде вдома до вечора. Ми можемо залишитися тут ще трохи? Я хочу, щоб ти знав правду. Ніколи більше так не роби, добре? Мені дуже шкода, що так сталося.",
+}
+
+// scriptLanguages maps scripts to the languages written in them. Text written mostly in one of
+// these scripts is identified by its script alone, as its first language, unless it has letters
+// foreign to that language, used by the other languages of the script, in which case its language
+// is ambiguous.
+var scriptLanguages = []struct {
+	script    *unicode.RangeTable
+	languages []string
+	foreign   string
+}{
+	{unicode.Hangul, []string{"ko"}, ""},
+	{unicode.Hiragana, []string{"ja"}, ""},
+	{unicode.Katakana, []string{"ja"}, ""},
+	{unicode.Han, []string{"zh"}, ""},
+	// Yiddish ligatures and rafe
+	{unicode.Hebrew, []string{"he", "yi"}, "\u05f0\u05f1\u05f2\u05bf"},
+	// Letters of Persian, Urdu, Pashto, Kurdish and Uyghur missing from the Arabic alphabet, including
+	// the Farsi yeh and keheh used instead of the Arabic yeh and kaf
+	{unicode.Arabic, []string{"ar", "fa", "ur", "ps", "ku", "ug"}, "پچژگکیٹڈڑںےھہۀۆێڕڵۇۈ"},
+	{unicode.Greek, []string{"el"}, ""},
+	{unicode.Armenian, []string{"hy"}, ""},
+	{unicode.Thai, []string{"th"}, ""},
+}
+
+var (
+	// languageProfiles holds the n-gram profile of each language in languageSamples.
+	languageProfiles = make(map[string]*languageProfile)
+)
+
+func init() {
+	for language, sample := range languageSamples {
+		languageProfiles[language] = newLanguageProfile(sample)
+	}
+}
+
+// LanguageGuess is a language detected in text, given as an ISO 639-1 code.
+type LanguageGuess struct {
+	Language string
+
+	// Confidence is between 0 and 1, where 0 means another language is as likely to be the
+	// language of the text.
+	Confidence float64
+}
+
+func (g LanguageGuess) String() string {
+	return fmt.Sprintf("%s (confidence %.2f)", g.Language, g.Confidence)
+}
+
+// LanguageDetector is implemented by translation service clients able to detect the language of text.
+type LanguageDetector interface {
+	// DetectLanguage returns the ISO 639-1 code of the language of the given texts.
+	DetectLanguage(texts []string) (string, error)
+}
+
+// DetectLanguage detects the language of the given text offline. Text written in a script of
+// scriptLanguages is identified by its script, and other text by its character n-grams. It fails
+// if the text is written in a script shared by several languages, and isn't told apart by its letters.
+func DetectLanguage(text string) (LanguageGuess, error) {
+	text = normalizeLanguageText(text)
+
+	letters := 0
+	scripts := make([]int, len(scriptLanguages))
+	foreign := make([]bool, len(scriptLanguages))
+	for _, r := range text {
+		if r == ' ' {
+			continue
+		}
+		letters++
+		for i, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				scripts[i]++
+				foreign[i] = foreign[i] || strings.ContainsRune(s.foreign, r)
+				break
+			}
+		}
+	}
+
+	if letters < minDetectionLetters {
+		return LanguageGuess{}, fmt.Errorf("Not enough text to detect its language")
+	}
+
+	// Japanese is written in a mix of kana and kanji, and identified by any significant use of kana
+	counts := make(map[string]int)
+	for i, s := range scriptLanguages {
+		counts[s.languages[0]] += scripts[i]
+	}
+	if kana := counts["ja"]; kana > 0 && kana*10 >= letters {
+		counts["ja"] += counts["zh"]
+		counts["zh"] = 0
+	}
+	for language, count := range counts {
+		if count*2 <= letters {
+			continue
+		}
+
+		for i, s := range scriptLanguages {
+			if s.languages[0] == language && foreign[i] {
+				return LanguageGuess{}, fmt.Errorf("The text is written in a script of several languages (%s), which can't be told apart",
+					strings.Join(s.languages, ", "))
+			}
+		}
+		return LanguageGuess{Language: language, Confidence: float64(count) / float64(letters)}, nil
+	}
+
+	return detectLanguageByProfile(text), nil
+}
+
+// detectLanguageByProfile detects the language of the given normalized text by its n-grams,
+// using a naive Bayes classifier over the n-gram frequencies of each language sample.
+func detectLanguageByProfile(text string) LanguageGuess {
+	counts := languageNgrams(text)
+
+	type score struct {
+		language string
+		score    float64
+	}
+	scores := make([]score, 0, len(languageProfiles))
+	for language, profile := range languageProfiles {
+		s := 0.0
+		for gram, count := range counts {
+			s += float64(count) * profile.logProbability(gram)
+		}
+		scores = append(scores, score{language, s})
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].score != scores[j].score {
+			return scores[i].score > scores[j].score
+		}
+		return scores[i].language < scores[j].language
+	})
+
+	// The confidence is derived from the likelihood ratio of the best and second best languages, per n-gram
+	best, second := scores[0], scores[1]
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	confidence := 1 - math.Exp((second.score-best.score)/float64(total))
+
+	// Text fitting the best language poorly is likely in a language without a profile
+	trigrams, covered := 0, 0
+	for gram, count := range counts {
+		if len([]rune(gram)) == maxLanguageNgram {
+			trigrams += count
+			if languageProfiles[best.language].counts[gram] > 0 {
+				covered += count
+			}
+		}
+	}
+	if float64(covered) < minProfileCoverage*float64(trigrams) {
+		confidence = 0
+	}
+
+	return LanguageGuess{Language: best.language, Confidence: confidence}
+}
+
+// detectableLanguage checks whether the given language may be detected by DetectLanguage: languages
+// with a profile, and the first language of each script. Other languages are detected as a related
+// language instead, or not at all.
+func detectableLanguage(language string) bool {
+	if _, ok := languageProfiles[language]; ok {
+		return true
+	}
+
+	for _, s := range scriptLanguages {
+		if s.languages[0] == language {
+			return true
+		}
+	}
+	return false
+}
+
+// languageProfile holds the n-gram counts of a language sample.
+type languageProfile struct {
+	counts map[string]int
+	totals [maxLanguageNgram + 1]int
+}
+
+func newLanguageProfile(sample string) *languageProfile {
+	profile := &languageProfile{
+		counts: languageNgrams(sample),
+	}
+	for gram, count := range profile.counts {
+		profile.totals[len([]rune(gram))] += count
+	}
+	return profile
+}
+
+// logProbability estimates the log probability of the given n-gram in the language, among the
+// n-grams of its length, using additive smoothing for n-grams missing from the sample.
+func (p *languageProfile) logProbability(gram string) float64 {
+	n := len([]rune(gram))
+	return math.Log((float64(p.counts[gram]) + languageNgramSmoothing) /
+		(float64(p.totals[n]) + languageNgramSmoothing*languageNgramSpace))
+}
+
+// languageNgrams counts the n-grams of the given text, of lengths 1 to maxLanguageNgram. Words are
+// padded by spaces, so that n-grams at word edges are distinct.
+func languageNgrams(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range strings.Fields(normalizeLanguageText(text)) {
+		runes := []rune(" " + word + " ")
+		for n := 1; n <= maxLanguageNgram; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				if gram := string(runes[i : i+n]); gram != " " {
+					counts[gram]++
+				}
+			}
+		}
+	}
+	return counts
+}
+
+// normalizeLanguageText lower-cases the given text, and keeps only its letters, separated by single spaces.
+func normalizeLanguageText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.Is(unicode.Mn, r):
+			return unicode.ToLower(r)
+		default:
+			return ' '
+		}
+	}, text)
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// languageText returns the text of the given subtitle file used to detect its language, up to the
+// given number of characters, excluding advertisements and hearing-impaired annotations.
+func languageText(subtitle *SubtitleFile, maxChars int) []string {
+	var texts []string
+	chars := 0
+	for i, entry := range subtitle.Entries {
+		if builtinAdDetector.IsAd(subtitle, i) {
+			continue
+		}
+
+		text := matchText(entry.Text)
+		if isWhitespace(text) {
+			continue
+		}
+
+		texts = append(texts, text)
+		chars += len(text)
+		if chars >= maxChars {
+			break
+		}
+	}
+	return texts
+}
+
+// DetectSubtitleLanguage detects the language of the given subtitle file offline.
+func DetectSubtitleLanguage(subtitle *SubtitleFile) (LanguageGuess, error) {
+	return DetectLanguage(strings.Join(languageText(subtitle, maxDetectionChars), "\n"))
+}
+
+// resolveSubtitleLanguage returns the language of the named subtitle file: the declared language
+// if given, and otherwise the detected language. The language is detected offline, and cross-checked
+// using the given detector, if not nil. Disagreements of the declared and detected languages are
+// reported as warnings, along with the detected language, to the given writer.
+func resolveSubtitleLanguage(name string, subtitle *SubtitleFile, declared string, detector LanguageDetector, messages io.Writer) (string, error) {
+	guess, err := DetectSubtitleLanguage(subtitle)
+
+	if detector != nil {
+		remote, remoteErr := detector.DetectLanguage(languageText(subtitle, maxRemoteDetectionChars))
+		remote = analyzerLanguage(remote)
+		switch {
+		case remoteErr != nil:
+			fmt.Fprintf(messages, "Warning: failed to cross-check the language of %s: %v\n", name, remoteErr)
+		case err != nil || guess.Confidence < minDetectionConfidence:
+			// The translation service is trusted over an uncertain offline detection
+			guess, err = LanguageGuess{Language: remote, Confidence: 1}, nil
+		case remote != guess.Language:
+			fmt.Fprintf(messages, "Warning: the language of %s was detected as %s, but as %s by the translation service\n",
+				name, guess, remote)
+		}
+	}
+
+	if declared == "" {
+		if err != nil {
+			return "", fmt.Errorf("Failed to detect the language of %s, please specify it: %v", name, err)
+		}
+		if guess.Confidence < minDetectionConfidence {
+			return "", fmt.Errorf("Failed to detect the language of %s with confidence, please specify it (best guess: %s)", name, guess)
+		}
+
+		fmt.Fprintf(messages, "Detected the language of %s as %s\n", name, guess)
+		return guess.Language, nil
+	}
+
+	// Languages which can't be detected would be reported as detected as related languages
+	language := analyzerLanguage(declared)
+	if err == nil && guess.Confidence >= minDetectionConfidence && guess.Language != language && detectableLanguage(language) {
+		fmt.Fprintf(messages, "Warning: the language of %s is declared as %s, but was detected as %s\n",
+			name, declared, guess)
+	}
+
+	return declared, nil
+}
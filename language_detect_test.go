@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		language string
+		text     string
+	}{
+		{"en", "Listen to me, nobody is going to hurt you. I promise I will find your brother."},
+		{"es", "Escúchame, nadie te va a hacer daño. Te prometo que encontraré a tu hermano."},
+		{"fr", "Écoute-moi, personne ne va te faire de mal. Je te promets que je retrouverai ton frère."},
+		{"de", "Hör mir zu, niemand wird dir wehtun. Ich verspreche dir, ich werde deinen Bruder finden."},
+		{"it", "Ascoltami, nessuno ti farà del male. Ti prometto che troverò tuo fratello."},
+		{"pt", "Escute, ninguém vai machucar você. Eu prometo que vou encontrar o seu irmão."},
+		{"nl", "Luister naar me, niemand gaat je pijn doen. Ik beloof dat ik je broer zal vinden."},
+		{"sv", "Lyssna på mig, ingen kommer att skada dig. Jag lovar att jag ska hitta din bror."},
+		{"pl", "Posłuchaj mnie, nikt nie zrobi ci krzywdy. Obiecuję, że znajdę twojego brata."},
+		{"cs", "Poslouchej mě, nikdo ti neublíží. Slibuji, že najdu tvého bratra."},
+		{"ro", "Ascultă-mă, nimeni nu o să-ți facă rău. Îți promit că o să-l găsesc pe fratele tău."},
+		{"tr", "Beni dinle, kimse sana zarar vermeyecek. Söz veriyorum, kardeşini bulacağım."},
+		{"id", "Dengarkan aku, tidak ada yang akan menyakitimu. Aku janji akan menemukan saudaramu."},
+		{"ru", "Послушай меня, никто не причинит тебе вреда. Обещаю, я найду твоего брата."},
+		{"uk", "Послухай мене, ніхто не заподіє тобі шкоди. Обіцяю, я знайду твого брата."},
+		{"bg", "Слушай ме, никой няма да те нарани. Обещавам, че ще намеря брат ти."},
+		{"he", "תקשיב לי, אף אחד לא יפגע בך. אני מבטיח שאמצא את אחיך."},
+		{"ar", "اسمعني، لن يؤذيك أحد. أعدك بأنني سأجد أخاك."},
+		{"el", "Άκουσέ με, κανείς δεν θα σε πειράξει. Σου υπόσχομαι ότι θα βρω τον αδελφό σου."},
+		{"ja", "聞いてくれ、誰も君を傷つけたりしない。君の兄弟を必ず見つけると約束する。"},
+		{"zh", "听我说，没有人会伤害你。我保证我会找到你的兄弟，我们一起回家吧。"},
+		{"ko", "내 말 들어, 아무도 너를 해치지 않아. 네 형을 꼭 찾겠다고 약속할게."},
+	}
+
+	for _, c := range cases {
+		guess, err := DetectLanguage(c.text)
+		if err != nil {
+			t.Errorf("Expected no error to occur while detecting the language of '%s', got error: %v", c.text, err)
+			continue
+		}
+		if guess.Language != c.language {
+			t.Errorf("Expected the language of '%s' to be detected as %s, got %v", c.text, c.language, guess)
+		}
+	}
+}
+
+func TestDetectLanguageAmbiguousScript(t *testing.T) {
+	for language, text := range map[string]string{
+		"fa": "به من گوش کن، هیچ کس به تو آسیبی نمی‌رساند. قول می‌دهم برادرت را پیدا کنم.",
+		"ur": "میری بات سنو، کوئی تمہیں نقصان نہیں پہنچائے گا۔ میں وعدہ کرتا ہوں کہ تمہارے بھائی کو ڈھونڈ لوں گا۔",
+		"yi": "הער מיך אױס, קײנער װעט דיר נישט טאָן קײן שלעכטס. איך זאָג צו, אַז איך װעל געפֿינען דײַן ברודער.",
+	} {
+		guess, err := DetectLanguage(text)
+		if err == nil {
+			t.Errorf("Expected an error to occur while detecting the language of %s text, got %v", language, guess)
+		}
+	}
+}
+
+func TestDetectLanguageWithoutProfile(t *testing.T) {
+	cases := []string{
+		// Danish, Norwegian, Finnish, Hungarian and Catalan
+		"Hør på mig, ingen kommer til at gøre dig noget. Jeg lover, at jeg vil finde din bror. Hvorfor fortalte du mig ikke sandheden fra starten? Jeg stoler ikke på nogen i denne by længere. I morgen tager vi tidligt af sted, så sov lidt.",
+		"Hør på meg, ingen kommer til å skade deg. Jeg lover at jeg skal finne broren din. Hvorfor fortalte du meg ikke sannheten fra starten? Jeg stoler ikke på noen i denne byen lenger. I morgen drar vi tidlig, så sov litt.",
+		"Kuuntele minua, kukaan ei satuta sinua. Lupaan, että löydän veljesi. Miksi et kertonut minulle totuutta alusta asti? En luota enää kehenkään tässä kaupungissa. Lähdemme huomenna aikaisin, joten nuku vähän.",
+		"Figyelj rám, senki nem fog bántani. Megígérem, hogy megtalálom a bátyádat. Miért nem mondtad el az igazat az elejétől? Már senkiben sem bízom ebben a városban. Holnap korán indulunk, szóval aludj egy kicsit.",
+		"Escolta'm, ningú no et farà mal. Et prometo que trobaré el teu germà. Per què no em vas dir la veritat des del principi? Ja no confio en ningú d'aquesta ciutat. Demà marxem d'hora, així que dorm una mica.",
+	}
+
+	for _, text := range cases {
+		guess, err := DetectLanguage(text)
+		if err == nil && guess.Confidence >= minDetectionConfidence {
+			t.Errorf("Expected the language of '%s' not to be detected with confidence, got %v", text, guess)
+		}
+	}
+
+	// Languages with a profile are still detected with confidence
+	guess, err := DetectLanguage("Escúchame, nadie te va a hacer daño. Te prometo que encontraré a tu hermano. ¿Por qué no me dijiste la verdad desde el principio? Ya no confío en nadie de esta ciudad. Mañana nos vamos temprano, así que duerme un poco.")
+	if err != nil || guess.Language != "es" || guess.Confidence < minDetectionConfidence {
+		t.Errorf("Expected the language to be detected as es with confidence, got %v (error: %v)", guess, err)
+	}
+}
+
+func TestDetectLanguageShortText(t *testing.T) {
+	_, err := DetectLanguage("Oh! 42...")
+	if err == nil {
+		t.Errorf("Expected an error to occur while detecting the language of too short text")
+	}
+}
+
+// fakeLanguageDetector detects a fixed language, or fails with a fixed error.
+type fakeLanguageDetector struct {
+	language string
+	err      error
+}
+
+func (d *fakeLanguageDetector) DetectLanguage(texts []string) (string, error) {
+	return d.language, d.err
+}
+
+func TestResolveSubtitleLanguage(t *testing.T) {
+	cases := []struct {
+		declared string
+		detector LanguageDetector
+		language string
+		message  string
+	}{
+		{"", nil, "en", "Detected the language of test.srt as en"},
+		{"en", nil, "en", ""},
+		{"eng", nil, "eng", ""},
+		{"fr", nil, "fr", "Warning: the language of test.srt is declared as fr, but was detected as en"},
+		{"", &fakeLanguageDetector{language: "en"}, "en", "Detected the language of test.srt as en"},
+		{"", &fakeLanguageDetector{language: "de"}, "en", "but as de by the translation service"},
+		{"en", &fakeLanguageDetector{err: errors.New("Forbidden")}, "en", "failed to cross-check the language of test.srt: Forbidden"},
+	}
+
+	for i, c := range cases {
+		messages := &bytes.Buffer{}
+		language, err := resolveSubtitleLanguage("test.srt", testSubtitle, c.declared, c.detector, messages)
+		if err != nil {
+			t.Errorf("Expected no error to occur while resolving the language (case %d), got error: %v", i, err)
+			continue
+		}
+
+		if language != c.language {
+			t.Errorf("Expected language %s (case %d), got %s", c.language, i, language)
+		}
+
+		if c.message == "" && messages.Len() > 0 || !strings.Contains(messages.String(), c.message) {
+			t.Errorf("Expected message '%s' (case %d), got '%s'", c.message, i, messages.String())
+		}
+	}
+
+	// The translation service is used when the language can't be detected offline
+	subtitle := &SubtitleFile{Entries: []*SubtitleEntry{{Index: 1, Text: []string{"Hi!"}}}}
+	language, err := resolveSubtitleLanguage("test.srt", subtitle, "", &fakeLanguageDetector{language: "en-US"}, &bytes.Buffer{})
+	if err != nil || language != "en" {
+		t.Errorf("Expected the language detected by the translation service, got %s (error: %v)", language, err)
+	}
+
+	_, err = resolveSubtitleLanguage("test.srt", subtitle, "", nil, &bytes.Buffer{})
+	if err == nil {
+		t.Errorf("Expected an error to occur while resolving an undetectable language")
+	}
+
+	// Languages which can't be detected aren't reported as detected as another language
+	persian := &SubtitleFile{Entries: []*SubtitleEntry{{Index: 1, Text: []string{"به من گوش کن، هیچ کس به تو آسیبی نمی‌رساند."}}}}
+	danish := &SubtitleFile{Entries: []*SubtitleEntry{{Index: 1, Text: []string{"Hør på mig, ingen kommer til at gøre dig noget. Jeg lover, at jeg vil finde din bror. Hvorfor fortalte du mig ikke sandheden fra starten? Jeg stoler ikke på nogen i denne by længere."}}}}
+	for _, c := range []struct {
+		subtitle *SubtitleFile
+		declared string
+	}{
+		{persian, "fa"},
+		{persian, "ur"},
+		{danish, "da"},
+	} {
+		messages := &bytes.Buffer{}
+		language, err := resolveSubtitleLanguage("test.srt", c.subtitle, c.declared, nil, messages)
+		if err != nil || language != c.declared || messages.Len() > 0 {
+			t.Errorf("Expected language %s without warnings, got %s (error: %v, messages: '%s')", c.declared, language, err, messages.String())
+		}
+
+		_, err = resolveSubtitleLanguage("test.srt", c.subtitle, "", nil, &bytes.Buffer{})
+		if err == nil {
+			t.Errorf("Expected an error to occur while detecting the language of %s text", c.declared)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLanguage(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected Language
+	}{
+		{"he", Language{Code: "he"}},
+		{"heb", Language{Code: "he"}},
+		{"iw", Language{Code: "he"}},
+		{"ENG", Language{Code: "en"}},
+		{"fre", Language{Code: "fr"}},
+		{"fra", Language{Code: "fr"}},
+		{"cmn", Language{Code: "zh"}},
+		{"ckb", Language{Code: "ckb"}},
+		{"pt-BR", Language{Code: "pt", Region: "BR"}},
+		{"pt_br", Language{Code: "pt", Region: "BR"}},
+		{"pob", Language{Code: "pt", Region: "BR"}},
+		{"zh-Hant", Language{Code: "zh", Script: "Hant"}},
+		{"zh-hant-tw", Language{Code: "zh", Script: "Hant", Region: "TW"}},
+		{"es-419", Language{Code: "es", Region: "419"}},
+		{"German", Language{Code: "de"}},
+		{"Norwegian Bokmål", Language{Code: "nb"}},
+	}
+
+	for _, c := range cases {
+		language, err := ParseLanguage(c.code)
+		if err != nil {
+			t.Errorf("Expected no error to occur while parsing '%s', got error: %v", c.code, err)
+			continue
+		}
+
+		if language != c.expected {
+			t.Errorf("Expected '%s' to be parsed as %+v, got %+v", c.code, c.expected, language)
+		}
+	}
+
+	for _, code := range []string{"", "xx", "klingon", "-BR"} {
+		_, err := ParseLanguage(code)
+		if err == nil {
+			t.Errorf("Expected an error to occur while parsing '%s'", code)
+		}
+	}
+}
+
+func TestLanguageCodes(t *testing.T) {
+	language, _ := ParseLanguage("heb")
+	if language.Tag() != "he" || language.ISO6392() != "heb" || language.Name() != "Hebrew" {
+		t.Errorf("Expected Hebrew to be he/heb, got %s/%s (%s)", language.Tag(), language.ISO6392(), language.Name())
+	}
+
+	language, _ = ParseLanguage("zh_hant_tw")
+	if language.Tag() != "zh-Hant-TW" || !language.TraditionalChinese() {
+		t.Errorf("Expected traditional Chinese tag zh-Hant-TW, got %s", language.Tag())
+	}
+
+	language, _ = ParseLanguage("zh-HK")
+	if !language.TraditionalChinese() {
+		t.Errorf("Expected Chinese of Hong Kong to be written in traditional characters")
+	}
+}
+
+func TestLanguageFromFilename(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"/movies/MyMovie/MyMovie.pt-BR.srt", "pt-BR"},
+		{"MyMovie.zh-Hant.srt", "zh-Hant"},
+		{"MyMovie.heb.srt", "he"},
+		{"MyMovie.English.srt", "en"},
+		{"MyMovie.2017.srt", ""},
+		{"MyMovie.srt", ""},
+	}
+
+	for _, c := range cases {
+		language, ok := LanguageFromFilename(c.path)
+		if ok != (c.expected != "") || ok && language.Tag() != c.expected {
+			t.Errorf("Expected the language of '%s' to be '%s', got '%s' (%v)", c.path, c.expected, language.Tag(), ok)
+		}
+	}
+}
+
+func TestProviderLanguageCodes(t *testing.T) {
+	cases := []struct {
+		provider string
+		from     string
+		to       string
+		fromCode string
+		toCode   string
+	}{
+		{"libre", "eng", "pt-BR", "en", "pb"},
+		{"libre", "", "zh-TW", "", "zt"},
+		{"deepl", "heb", "en", "", ""},
+		{"deepl", "en-GB", "en-GB", "EN", "EN-GB"},
+		{"deepl", "fre", "pt", "FR", "PT-PT"},
+		{"deepl", "jpn", "zh-Hant", "JA", "ZH-HANT"},
+		{"deepl", "fr", "no", "FR", "NB"},
+		{"google", "zh-Hant", "zh", "zh-TW", "zh-CN"},
+		{"google", "heb", "English", "he", "en"},
+		{"microsoft", "zh-TW", "zh-Hans", "zh-CHT", "zh-CHS"},
+		{"dictionary", "eng", "fr-CA", "en", "fr"},
+	}
+
+	for _, c := range cases {
+		from, to, err := translationProviders[c.provider].languageCodes(c.from, c.to)
+		if c.toCode == "" {
+			if err == nil || !strings.Contains(err.Error(), "doesn't support") {
+				t.Errorf("Expected %s to fail translating from %s to %s, got %v", c.provider, c.from, c.to, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected no error to occur while converting %s-%s for %s, got error: %v", c.from, c.to, c.provider, err)
+			continue
+		}
+
+		if from != c.fromCode || to != c.toCode {
+			t.Errorf("Expected %s-%s to be converted to %s-%s for %s, got %s-%s",
+				c.from, c.to, c.fromCode, c.toCode, c.provider, from, to)
+		}
+	}
+
+	_, _, err := translationProviders["libre"].languageCodes("en", "klingon")
+	if err == nil || !strings.Contains(err.Error(), "Unknown language: klingon") {
+		t.Errorf("Expected an error to occur while translating into an unknown language, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLintMinDuration       = 700 * time.Millisecond
+	defaultLintMaxDuration       = 7 * time.Second
+	defaultLintMaxCharsPerSecond = 21
+)
+
+// LintOptions control which problems Lint reports. Zero valued limits aren't checked.
+type LintOptions struct {
+	// MaxLineLength is the maximal width of a line, as displayed.
+	MaxLineLength int
+
+	// MaxLines is the maximal number of lines of an entry.
+	MaxLines int
+
+	// MinDuration and MaxDuration bound the time an entry is displayed for.
+	MinDuration time.Duration
+	MaxDuration time.Duration
+
+	// MaxCharsPerSecond is the maximal reading speed of an entry.
+	MaxCharsPerSecond float64
+}
+
+// LintIssue is a problem found in a subtitle entry.
+type LintIssue struct {
+	Index   int
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%d: %s", i.Index, i.Message)
+}
+
+// Lint checks the given subtitle file for problems: entries out of sequence or overlapping each
+// other, entries with invalid timing or no text, and entries exceeding the limits of the given
+// options. The issues are returned in the order of the entries.
+func Lint(subtitle *SubtitleFile, options LintOptions) []LintIssue {
+	var issues []LintIssue
+	report := func(entry *SubtitleEntry, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{Index: entry.Index, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var previous *SubtitleEntry
+	for i, entry := range subtitle.Entries {
+		if entry.Index != i+1 {
+			report(entry, "Expected index %d", i+1)
+		}
+
+		duration := entry.End - entry.Start
+		switch {
+		case duration <= 0:
+			report(entry, "Ends at %s, before it starts at %s", timestampString(entry.End), timestampString(entry.Start))
+		case options.MinDuration > 0 && duration < options.MinDuration:
+			report(entry, "Displayed for %v, shorter than %v", duration, options.MinDuration)
+		case options.MaxDuration > 0 && duration > options.MaxDuration:
+			report(entry, "Displayed for %v, longer than %v", duration, options.MaxDuration)
+		}
+
+		if previous != nil {
+			switch {
+			case entry.Start < previous.Start:
+				report(entry, "Starts before the previous entry %d", previous.Index)
+			case entry.Start < previous.End:
+				report(entry, "Overlaps the previous entry %d by %v", previous.Index, previous.End-entry.Start)
+			}
+		}
+		previous = entry
+
+		// Styling tags aren't displayed, so they're excluded from lengths
+		length := 0
+		for n, line := range entry.Text {
+			width := textWidth(strings.TrimSpace(styleTagRegexp.ReplaceAllString(line, "")))
+			length += width
+			if options.MaxLineLength > 0 && width > options.MaxLineLength {
+				report(entry, "Line %d is %d characters long, longer than %d", n+1, width, options.MaxLineLength)
+			}
+		}
+
+		if length == 0 {
+			report(entry, "Has no text")
+			continue
+		}
+
+		if options.MaxLines > 0 && len(entry.Text) > options.MaxLines {
+			report(entry, "Has %d lines, more than %d", len(entry.Text), options.MaxLines)
+		}
+
+		if options.MaxCharsPerSecond > 0 && duration > 0 {
+			speed := float64(length) / duration.Seconds()
+			if speed > options.MaxCharsPerSecond {
+				report(entry, "Reading speed is %.1f characters per second, faster than %v", speed, options.MaxCharsPerSecond)
+			}
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("1s"), End: mustParseDuration("3s"), Text: []string{"<i>Fine.</i>"}},
+			{Index: 2, Start: mustParseDuration("2s500ms"), End: mustParseDuration("4s"), Text: []string{"Overlapping."}},
+			{Index: 4, Start: mustParseDuration("5s"), End: mustParseDuration("5s200ms"), Text: []string{"Short."}},
+			{Index: 4, Start: mustParseDuration("7s"), End: mustParseDuration("6s"), Text: []string{"Backwards."}},
+			{Index: 5, Start: mustParseDuration("6s500ms"), End: mustParseDuration("8s"), Text: []string{" "}},
+			{Index: 6, Start: mustParseDuration("10s"), End: mustParseDuration("20s"), Text: []string{"This line is much longer than allowed."}},
+			{Index: 7, Start: mustParseDuration("21s"), End: mustParseDuration("22s"), Text: []string{"One", "Two", "Three and more words."}},
+		},
+	}
+
+	issues := Lint(subtitle, LintOptions{
+		MaxLineLength:     20,
+		MaxLines:          2,
+		MinDuration:       mustParseDuration("500ms"),
+		MaxDuration:       mustParseDuration("7s"),
+		MaxCharsPerSecond: 25,
+	})
+
+	expected := []LintIssue{
+		{2, "Overlaps the previous entry 1 by 500ms"},
+		{4, "Expected index 3"},
+		{4, "Displayed for 200ms, shorter than 500ms"},
+		{4, "Reading speed is 30.0 characters per second, faster than 25"},
+		{4, "Ends at 00:00:06,000, before it starts at 00:00:07,000"},
+		{5, "Starts before the previous entry 4"},
+		{5, "Has no text"},
+		{6, "Displayed for 10s, longer than 7s"},
+		{6, "Line 1 is 38 characters long, longer than 20"},
+		{7, "Line 3 is 21 characters long, longer than 20"},
+		{7, "Has 3 lines, more than 2"},
+		{7, "Reading speed is 27.0 characters per second, faster than 25"},
+	}
+
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected issues:\n%v\ngot:\n%v", expected, issues)
+	}
+}
+
+func TestLintDisabledChecks(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("1s"), End: mustParseDuration("1s100ms"), Text: []string{"A line too long to read in a tenth of a second.", "Two", "Three"}},
+		},
+	}
+
+	issues := Lint(subtitle, LintOptions{})
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues with all limits disabled, got %v", issues)
+	}
+}
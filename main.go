@@ -1,22 +1,63 @@
 package main
 
 import (
-	"flag"
+	"fmt"
+	"os"
+	"strings"
 )
 
-// Flags
-var (
-	inputFile string
-	inputLanguage string
-
-	referenceFile string
-	referenceLanguage string
-)
 func main() {
-	flag.StringVar(&inputFile, "input-file", "", "Path to subtitle file to synchronize")
-	flag.StringVar(&inputLanguage, "input-lang", "", "Language of subtitle file to synchronize")
-	flag.StringVar(&referenceFile, "ref-file", "", "Path to reference subtitle file")
-	flag.StringVar(&referenceLanguage, "ref-lang", "", "Langauge of reference subtitle file")
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			cmd, ok := commands[args[1]]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "subsyncer: unknown command: %s\n\n", args[1])
+				usage()
+				os.Exit(2)
+			}
+			cmd.flagSet().Usage()
+			return
+		}
+		usage()
+		return
+	}
+
+	cmd, ok := commands[args[0]]
+	switch {
+	case ok:
+		args = args[1:]
+	case strings.HasPrefix(args[0], "-"):
+		// Invoked with flags only, as before subsyncer had commands
+		cmd = commands["sync"]
+	default:
+		fmt.Fprintf(os.Stderr, "subsyncer: unknown command: %s\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.execute(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "subsyncer %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: subsyncer <command> [flags] [file]\n\n")
+	fmt.Fprintf(os.Stderr, "Subtitle files are read from the standard input, and written to the standard output,\n")
+	fmt.Fprintf(os.Stderr, "unless given a file, or the --output or --in-place flags.\n")
+
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	for _, name := range commandNames() {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].description)
+	}
 
-	flag.Parse()
-}
\ No newline at end of file
+	fmt.Fprintf(os.Stderr, "\nRun \"subsyncer help <command>\" for the flags and examples of a command.\n")
+}
@@ -1 +1,92 @@
 package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// minOffsetMatches is the minimal number of input entries matched in the reference subtitle
+	// file for an offset to be estimated.
+	minOffsetMatches = 3
+
+	// offsetRefinementRadius is the time window around their coarsely synchronized time, within
+	// which input entries are searched for again to refine the offset.
+	offsetRefinementRadius = 10 * time.Second
+)
+
+// EstimateOffset estimates the constant duration the entries of the given input subtitle file must
+// be shifted by to be synchronized with the indexed reference subtitle file. Each input entry is
+// searched for in the reference subtitle file, and the offset is the median of the differences
+// between the start times of the input entries and of their best matches, so that mismatched
+// entries don't skew it. The offset is then refined by searching for each input entry again,
+// near its time once shifted by the offset, so that lines repeated throughout the reference
+// subtitle file, e.g. "Thank you.", are matched with their nearby occurrence. Searches use up to
+// workers goroutines, as described by SearchBulk. It fails if fewer than minOffsetMatches entries
+// are matched.
+func EstimateOffset(input *SubtitleFile, reference IndexedSubtitle, workers int) (time.Duration, error) {
+	queries := make([]SearchQuery, 0, len(input.Entries))
+	entries := make([]*SubtitleEntry, 0, len(input.Entries))
+	for _, entry := range input.Entries {
+		text := matchText(entry.Text)
+		if text == "" {
+			continue
+		}
+
+		queries = append(queries, SearchQuery{Text: text, K: 1})
+		entries = append(entries, entry)
+	}
+
+	offsets, err := matchOffsets(reference, queries, entries, workers)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(offsets) < minOffsetMatches {
+		return 0, fmt.Errorf("Only %d of %d entries matched the reference subtitle file, too few to synchronize",
+			len(offsets), len(input.Entries))
+	}
+	offset := medianOffset(offsets)
+
+	for i, entry := range entries {
+		queries[i].At = entry.Start + offset
+		queries[i].Radius = offsetRefinementRadius
+	}
+
+	offsets, err = matchOffsets(reference, queries, entries, workers)
+	if err != nil {
+		return 0, err
+	}
+
+	// The coarse offset is kept if too few entries are matched near it
+	if len(offsets) < minOffsetMatches {
+		return offset, nil
+	}
+	return medianOffset(offsets), nil
+}
+
+// matchOffsets searches for the given queries, of the given input entries, in the reference
+// subtitle file, returning the differences between the start times of the matched input entries
+// and of their best matches.
+func matchOffsets(reference IndexedSubtitle, queries []SearchQuery, entries []*SubtitleEntry, workers int) ([]time.Duration, error) {
+	offsets := make([]time.Duration, 0, len(queries))
+	for i, result := range SearchBulk(reference, queries, workers) {
+		switch result.Err {
+		case nil:
+			offsets = append(offsets, result.Results[0].Entry.Start-entries[i].Start)
+		case ErrNoHits, ErrBelowThreshold:
+			// Entries missing from the reference subtitle file, or translated too differently
+		default:
+			return nil, result.Err
+		}
+	}
+
+	return offsets, nil
+}
+
+// medianOffset returns the median of the given offsets, sorting them.
+func medianOffset(offsets []time.Duration) time.Duration {
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets[len(offsets)/2]
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// nearRecordingIndexedSubtitle is an IndexedSubtitle recording the time windows searched by SearchNear.
+type nearRecordingIndexedSubtitle struct {
+	IndexedSubtitle
+
+	mutex   sync.Mutex
+	windows map[time.Duration]time.Duration
+}
+
+func (r *nearRecordingIndexedSubtitle) SearchNear(text string, k int, at, radius time.Duration) ([]*SearchResult, error) {
+	r.mutex.Lock()
+	r.windows[at] = radius
+	r.mutex.Unlock()
+
+	return r.IndexedSubtitle.SearchNear(text, k, at, radius)
+}
+
+func TestEstimateOffset(t *testing.T) {
+	reference := newBenchmarkSubtitle(40)
+	indexedSub, err := NewIndexedSubtitle(reference, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+	defer indexedSub.Close()
+
+	input := &SubtitleFile{
+		Entries: make([]*SubtitleEntry, len(reference.Entries)),
+	}
+	for i, entry := range reference.Entries {
+		shifted := *entry
+		shifted.Start -= 7 * time.Second
+		shifted.End -= 7 * time.Second
+		input.Entries[i] = &shifted
+	}
+
+	// Entries missing from the reference subtitle file are ignored
+	input.Entries[3].Text = []string{"Xylophone quartet"}
+	input.Entries[10].Text = []string{}
+
+	recording := &nearRecordingIndexedSubtitle{IndexedSubtitle: indexedSub, windows: make(map[time.Duration]time.Duration)}
+	offset, err := EstimateOffset(input, recording, 0)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while estimating the offset, got error: %v", err)
+	}
+
+	if offset != 7*time.Second {
+		t.Errorf("Expected an offset of 7s, got %v", offset)
+	}
+
+	// The offset is refined by searching for entries near their shifted time
+	for i, entry := range input.Entries {
+		if i == 10 {
+			continue
+		}
+
+		if radius, ok := recording.windows[entry.Start+offset]; !ok || radius != offsetRefinementRadius {
+			t.Errorf("Expected entry %d to be searched for near %v, got windows %v", entry.Index, entry.Start+offset, recording.windows)
+			break
+		}
+	}
+
+	_, err = EstimateOffset(&SubtitleFile{Entries: input.Entries[3:4]}, indexedSub, 0)
+	if err == nil {
+		t.Errorf("Expected an error to occur while estimating the offset of unmatched entries")
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// minNgramSimilarity is the minimal similarity of entries matched by the ngram backend.
+	minNgramSimilarity = 0.3
+
+	cosineSimilarity  = "cosine"
+	jaccardSimilarity = "jaccard"
+	editSimilarity    = "edit"
+)
+
+// ngramIndexedSubtitle is an IndexedSubtitle matching text by the similarity of character n-grams
+// (shingles), rather than by terms. It suits languages written without spaces between words,
+// and tolerates machine translation output which doesn't match the reference wording exactly.
+type ngramIndexedSubtitle struct {
+	subtitle   *SubtitleFile
+	n          int
+	similarity string
+
+	// grams holds the n-gram counts of each entry, by entry position.
+	grams []map[string]int
+
+	// norms holds the euclidean norm of each entry n-gram counts vector, by entry position.
+	norms []float64
+
+	// postings maps each n-gram to the positions of the entries containing it.
+	postings map[string][]int
+}
+
+func newNgramIndexedSubtitle(subtitle *SubtitleFile, options IndexOptions) (IndexedSubtitle, error) {
+	similarity := options.Similarity
+	if similarity == "" {
+		similarity = cosineSimilarity
+	}
+
+	switch similarity {
+	case cosineSimilarity, jaccardSimilarity, editSimilarity:
+	default:
+		return nil, fmt.Errorf("Unknown similarity measure: %s", similarity)
+	}
+
+	nis := &ngramIndexedSubtitle{
+		subtitle:   subtitle,
+		n:          ngramSize(options.Language),
+		similarity: similarity,
+		grams:      make([]map[string]int, len(subtitle.Entries)),
+		norms:      make([]float64, len(subtitle.Entries)),
+		postings:   make(map[string][]int),
+	}
+
+	ads := options.Ads.Ads(subtitle)
+	for i, entry := range subtitle.Entries {
+		if ads != nil && ads[i] {
+			continue
+		}
+
+		grams := nis.ngrams(matchText(entry.Text))
+		nis.grams[i] = grams
+		nis.norms[i] = norm(grams)
+		for gram := range grams {
+			nis.postings[gram] = append(nis.postings[gram], i)
+		}
+	}
+
+	return nis, nil
+}
+
+// ngramSize selects the n-gram size for the given language: bigrams for languages written
+// with ideographs or syllabaries, where each character carries more information, and trigrams otherwise.
+func ngramSize(language string) int {
+	switch analyzerLanguage(language) {
+	case "zh", "ja", "ko":
+		return 2
+	default:
+		return 3
+	}
+}
+
+func (nis *ngramIndexedSubtitle) Search(text string) (*SubtitleEntry, error) {
+	results, err := nis.SearchCandidates(text, 1)
+	if err == ErrNoHits || err == ErrBelowThreshold {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results[0].Entry, nil
+}
+
+func (nis *ngramIndexedSubtitle) SearchCandidates(text string, k int) ([]*SearchResult, error) {
+	return nis.search(text, k, nil)
+}
+
+func (nis *ngramIndexedSubtitle) SearchNear(text string, k int, at, radius time.Duration) ([]*SearchResult, error) {
+	return nis.search(text, k, func(entry *SubtitleEntry) float64 {
+		if entry.End < at-radius || entry.Start > at+radius {
+			return 0
+		}
+		return proximity(entry, at, radius)
+	})
+}
+
+func (nis *ngramIndexedSubtitle) Close() error {
+	return nil
+}
+
+// search scores all entries sharing any n-gram with the given text, returning up to k results as
+// described by SearchCandidates. If boost is not nil, result scores are multiplied by the boost of
+// their entry, and entries with a zero boost are excluded.
+func (nis *ngramIndexedSubtitle) search(text string, k int, boost func(entry *SubtitleEntry) float64) ([]*SearchResult, error) {
+	query := matchText([]string{text})
+	grams := nis.ngrams(query)
+
+	// Compute the dot product, as well as the shared n-grams, with each candidate entry.
+	dots := make(map[int]float64)
+	shared := make(map[int][]string)
+	for gram, count := range grams {
+		for _, i := range nis.postings[gram] {
+			dots[i] += float64(count * nis.grams[i][gram])
+			shared[i] = append(shared[i], gram)
+		}
+	}
+
+	// Iterate candidates by position, so that equally scored results keep their order.
+	candidates := make([]int, 0, len(dots))
+	for i := range dots {
+		candidates = append(candidates, i)
+	}
+	sort.Ints(candidates)
+
+	queryNorm := norm(grams)
+	results := make([]*SearchResult, 0, len(candidates))
+	for _, i := range candidates {
+		entry := nis.subtitle.Entries[i]
+		sort.Strings(shared[i])
+
+		var score float64
+		switch nis.similarity {
+		case cosineSimilarity:
+			score = dots[i] / (queryNorm * nis.norms[i])
+		case jaccardSimilarity:
+			score = float64(len(shared[i])) / float64(len(grams)+len(nis.grams[i])-len(shared[i]))
+		case editSimilarity:
+			score = normalizedEditSimilarity(normalizeNgramText(query), normalizeNgramText(matchText(entry.Text)))
+		}
+
+		result := &SearchResult{
+			Entry:        entry,
+			Score:        score,
+			MatchedTerms: shared[i],
+			rawScore:     score,
+		}
+		if boost != nil {
+			result.Score *= boost(entry)
+			if result.Score == 0 {
+				continue
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return rankResults(results, minNgramSimilarity, k)
+}
+
+// ngrams returns the counts of the character n-grams of the given text, once normalized.
+// Word boundaries are represented by a space, so that n-grams spanning word edges are distinct.
+func (nis *ngramIndexedSubtitle) ngrams(text string) map[string]int {
+	runes := []rune(" " + normalizeNgramText(text) + " ")
+	grams := make(map[string]int)
+	if len(runes) <= nis.n {
+		if len(runes) > 2 {
+			grams[string(runes)]++
+		}
+		return grams
+	}
+
+	for i := 0; i+nis.n <= len(runes); i++ {
+		grams[string(runes[i:i+nis.n])]++
+	}
+
+	return grams
+}
+
+// normalizeNgramText lower-cases the given text, and removes punctuation and redundant whitespace.
+func normalizeNgramText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			return unicode.ToLower(r)
+		default:
+			return ' '
+		}
+	}, text)
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// normalizedEditSimilarity computes the similarity of the given strings as 1 minus their
+// Levenshtein distance, relative to the length of the longer string.
+func normalizedEditSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	// Compute the distance row by row, keeping only the previous row.
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// norm computes the euclidean norm of the given n-gram counts vector.
+func norm(grams map[string]int) float64 {
+	sum := 0
+	for _, count := range grams {
+		sum += count * count
+	}
+
+	return math.Sqrt(float64(sum))
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNgramIndexedSubtitleSearchExact(t *testing.T) {
+	for _, similarity := range []string{cosineSimilarity, jaccardSimilarity, editSimilarity} {
+		indexedSub, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en", Backend: ngramBackend, Similarity: similarity})
+		if err != nil {
+			t.Fatalf("Expected no error to occur while indexing subtitle (%s), got error: %v", similarity, err)
+		}
+
+		for _, entry := range testSubtitle.Entries {
+			resEntry, err := indexedSub.Search(matchText(entry.Text))
+			if err != nil {
+				t.Fatalf("Got error while searching entry %d (%s): %v", entry.Index, similarity, err)
+			}
+
+			if resEntry == nil || !equalSubtitleEntries(entry, resEntry) {
+				t.Errorf("Got wrong result while searching entry %d (%s): Expected %v, got %v",
+					entry.Index, similarity, entry, resEntry)
+			}
+		}
+	}
+}
+
+func TestNgramIndexedSubtitleSearchCJK(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Text: []string{"孩子们在花园里玩耍。"}},
+			{Index: 2, Text: []string{"我们明天早上出发吧！"}},
+			{Index: 3, Text: []string{"你昨天晚上去哪儿了？"}},
+		},
+	}
+
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "zh"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	if _, ok := indexedSub.(*ngramIndexedSubtitle); !ok {
+		t.Errorf("Expected the ngram backend to be selected for Chinese")
+	}
+
+	cases := []struct {
+		text  string
+		index int
+	}{
+		{"孩子们在花园玩", 1},
+		{"明天早上我们出发", 2},
+		{"昨天晚上你去哪儿？", 3},
+	}
+
+	for i, c := range cases {
+		resEntry, err := indexedSub.Search(c.text)
+		if err != nil {
+			t.Fatalf("Got error while searching (case %d): %v", i, err)
+		}
+
+		if resEntry == nil || resEntry.Index != c.index {
+			t.Errorf("Expected entry %d to be found (case %d), got %v", c.index, i, resEntry)
+		}
+	}
+
+	_, err = indexedSub.SearchCandidates("完全不相关", 3)
+	if err != ErrNoHits {
+		t.Errorf("Expected ErrNoHits while searching unrelated text, got %v", err)
+	}
+}
+
+func TestNgramIndexedSubtitleSearchNear(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("1m"), End: mustParseDuration("1m2s"), Text: []string{"走吧！"}},
+			{Index: 2, Start: mustParseDuration("10m"), End: mustParseDuration("10m2s"), Text: []string{"走吧！"}},
+			{Index: 3, Start: mustParseDuration("30m"), End: mustParseDuration("30m2s"), Text: []string{"走吧！"}},
+		},
+	}
+
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "zh"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	results, err := indexedSub.SearchNear("走吧", 3, mustParseDuration("10m30s"), mustParseDuration("1m"))
+	if err != nil {
+		t.Fatalf("Got error while searching: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Entry.Index != 2 {
+		t.Errorf("Expected only entry 2 to be found, got %v", results)
+	}
+}
+
+func TestNormalizedEditSimilarity(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected float64
+	}{
+		{"", "", 1},
+		{"kitten", "kitten", 1},
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"abc", "", 0},
+		{"花园里", "花园", 1 - 1.0/3},
+	}
+
+	for _, c := range cases {
+		actual := normalizedEditSimilarity(c.a, c.b)
+		if math.Abs(actual-c.expected) > 1e-9 {
+			t.Errorf("Expected similarity of '%s' and '%s' to be %v, got %v", c.a, c.b, c.expected, actual)
+		}
+	}
+}
+
+func TestNewIndexedSubtitleUnknownBackend(t *testing.T) {
+	_, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en", Backend: "unknown"})
+	if err == nil {
+		t.Errorf("Expected an error to occur while using an unknown backend")
+	}
+
+	_, err = NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en", Backend: ngramBackend, Similarity: "unknown"})
+	if err == nil {
+		t.Errorf("Expected an error to occur while using an unknown similarity measure")
+	}
+}
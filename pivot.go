@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MatchingSubtitles holds the input and reference subtitle files brought into a common language,
+// in which their entries are matched.
+type MatchingSubtitles struct {
+	Input     *SubtitleFile
+	Reference *SubtitleFile
+
+	// Language is the common language of the subtitle files, used to index the reference subtitle file.
+	Language string
+
+	// InputReport and ReferenceReport report the translation of each subtitle file, or are nil if
+	// it wasn't translated.
+	InputReport     *TranslationReport
+	ReferenceReport *TranslationReport
+}
+
+// translateForMatching brings the given input and reference subtitle files into a common language.
+// Subtitle files in the same language are not translated. Otherwise, if a pivot language is given,
+// both subtitle files are translated into it, unless already in it, and if not, the input subtitle
+// file is translated into the language of the reference subtitle file. The translator is only
+// created if translation is needed.
+func translateForMatching(input, reference *SubtitleFile, inputLanguage, referenceLanguage, pivot string,
+	translator func() (Translator, error)) (*MatchingSubtitles, error) {
+
+	matching := &MatchingSubtitles{
+		Input:     input,
+		Reference: reference,
+		Language:  referenceLanguage,
+	}
+
+	if sameLanguage(inputLanguage, referenceLanguage) {
+		return matching, nil
+	}
+
+	if pivot != "" {
+		matching.Language = pivot
+	}
+
+	var t Translator
+	translate := func(subtitle *SubtitleFile, name, from string) (*SubtitleFile, *TranslationReport, error) {
+		if t == nil {
+			var err error
+			t, err = translator()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		tSubtitle, report, err := t.Translate(subtitle, from, matching.Language)
+		if err != nil {
+			return nil, report, fmt.Errorf("Failed to translate the %s subtitle file from %s to %s: %v", name, from, matching.Language, err)
+		}
+		return tSubtitle, report, nil
+	}
+
+	var err error
+	if !sameLanguage(inputLanguage, matching.Language) {
+		matching.Input, matching.InputReport, err = translate(input, "input", inputLanguage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !sameLanguage(referenceLanguage, matching.Language) {
+		matching.Reference, matching.ReferenceReport, err = translate(reference, "reference", referenceLanguage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return matching, nil
+}
+
+// sameLanguage checks whether the given language codes, in any form accepted by ParseLanguage,
+// stand for the same language, ignoring regions. Chinese written in simplified and traditional
+// characters are considered different languages, as their texts don't match.
+func sameLanguage(a, b string) bool {
+	aLanguage, aErr := ParseLanguage(a)
+	bLanguage, bErr := ParseLanguage(b)
+	if aErr != nil || bErr != nil {
+		return strings.EqualFold(a, b)
+	}
+
+	return aLanguage.Code == bLanguage.Code && aLanguage.TraditionalChinese() == bLanguage.TraditionalChinese()
+}
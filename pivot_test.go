@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// recordingTranslator is a Translator recording the language pairs of its translations,
+// and returning the subtitle files as is.
+type recordingTranslator struct {
+	pairs []string
+}
+
+func (r *recordingTranslator) Translate(subtitle *SubtitleFile, from, to string) (*SubtitleFile, *TranslationReport, error) {
+	r.pairs = append(r.pairs, from+"-"+to)
+	return copySubtitle(subtitle), &TranslationReport{Entries: len(subtitle.Entries)}, nil
+}
+
+func TestTranslateForMatching(t *testing.T) {
+	cases := []struct {
+		input     string
+		reference string
+		pivot     string
+		language  string
+		pairs     string
+	}{
+		{"en", "en", "", "en", "[]"},
+		{"eng", "en-US", "", "en-US", "[]"},
+		{"heb", "heb", "en", "heb", "[]"},
+		{"he", "en", "", "en", "[he-en]"},
+		{"he", "ko", "en", "en", "[he-en ko-en]"},
+		{"he", "eng", "en", "en", "[he-en]"},
+		{"zh-Hans", "zh-Hant", "", "zh-Hant", "[zh-Hans-zh-Hant]"},
+	}
+
+	for _, c := range cases {
+		translator := &recordingTranslator{}
+		created := false
+		matching, err := translateForMatching(testSubtitle, testSubtitle, c.input, c.reference, c.pivot, func() (Translator, error) {
+			created = true
+			return translator, nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error to occur while translating %s/%s, got error: %v", c.input, c.reference, err)
+			continue
+		}
+
+		if matching.Language != c.language || fmt.Sprint(translator.pairs) != c.pairs {
+			t.Errorf("Expected %s/%s (pivot '%s') to be matched in %s after translating %s, got %s after translating %v",
+				c.input, c.reference, c.pivot, c.language, c.pairs, matching.Language, translator.pairs)
+		}
+
+		if created != (len(translator.pairs) > 0) {
+			t.Errorf("Expected the translator to be created only if needed (%s/%s)", c.input, c.reference)
+		}
+
+		if (matching.InputReport != nil) != (matching.Input != testSubtitle) ||
+			(matching.ReferenceReport != nil) != (matching.Reference != testSubtitle) {
+			t.Errorf("Expected reports of translated subtitle files only (%s/%s)", c.input, c.reference)
+		}
+	}
+
+	_, err := translateForMatching(testSubtitle, testSubtitle, "he", "en", "", func() (Translator, error) {
+		return nil, errors.New("No translation service")
+	})
+	if err == nil {
+		t.Errorf("Expected an error to occur while translating with no translator")
+	}
+}
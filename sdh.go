@@ -0,0 +1,94 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	speakerLabelRegexp = regexp.MustCompile(`^(-\s*)?\p{Lu}[\p{Lu}\d '.#-]*[\p{Lu}\d]\s*:(\s+|$)`)
+	whitespaceRegexp   = regexp.MustCompile(`\s+`)
+)
+
+// StripSDH removes hearing-impaired (SDH) annotations from all entries of the subtitle file,
+// i.e. sound descriptions such as "[door slams]" or "(SIGHS)", song lyrics enclosed in "♪",
+// and speaker labels such as "JOHN:". Entries left with no text are dropped, and the
+// remaining entries are renumbered.
+func (f *SubtitleFile) StripSDH() {
+	entries := make([]*SubtitleEntry, 0, len(f.Entries))
+	for _, entry := range f.Entries {
+		entry.Text = stripSDHLines(entry.Text)
+		if len(entry.Text) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+
+	f.Entries = entries
+	f.Renumber()
+}
+
+// stripSDHLines removes hearing-impaired annotations from the given lines of a single entry.
+// Annotations may span multiple lines, but not entries: brackets or music notes which aren't
+// closed within the entry are kept as text. Lines which are left with no text are omitted.
+func stripSDHLines(lines []string) []string {
+	runes := []rune(strings.Join(lines, "\n"))
+	removed := make([]bool, len(runes))
+	remove := func(from, to int) {
+		for i := from; i <= to; i++ {
+			removed[i] = true
+		}
+	}
+
+	var brackets []int
+	music := -1
+	for i, r := range runes {
+		switch {
+		case r == '[' || r == '(':
+			brackets = append(brackets, i)
+		case (r == ']' || r == ')') && len(brackets) > 0:
+			open := brackets[len(brackets)-1]
+			brackets = brackets[:len(brackets)-1]
+			if len(brackets) == 0 {
+				remove(open, i)
+			}
+		case (r == '♪' || r == '♫') && music < 0:
+			music = i
+		case r == '♪' || r == '♫':
+			remove(music, i)
+			music = -1
+		}
+	}
+
+	stripped := make([]string, 0, len(lines))
+	for _, line := range strings.Split(string(keptRunes(runes, removed)), "\n") {
+		text := speakerLabelRegexp.ReplaceAllString(line, "$1")
+		text = strings.TrimSpace(whitespaceRegexp.ReplaceAllString(text, " "))
+		if !hasWords(text) {
+			continue
+		}
+
+		stripped = append(stripped, text)
+	}
+
+	return stripped
+}
+
+// keptRunes returns the given runes which aren't removed, keeping line breaks.
+func keptRunes(runes []rune, removed []bool) []rune {
+	kept := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		if !removed[i] || r == '\n' {
+			kept = append(kept, r)
+		}
+	}
+
+	return kept
+}
+
+// hasWords determines whether the given string contains any letters or digits.
+func hasWords(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStripSDHLines(t *testing.T) {
+	cases := []struct {
+		lines    []string
+		expected []string
+	}{
+		{[]string{"Where are you going?"}, []string{"Where are you going?"}},
+		{[]string{"[door slams]"}, []string{}},
+		{[]string{"(SIGHS) I don't know."}, []string{"I don't know."}},
+		{[]string{"JOHN: Wait for me!"}, []string{"Wait for me!"}},
+		{[]string{"- MAN 2: Over here!", "- [gunshot]"}, []string{"- Over here!"}},
+		{[]string{"- Hello.", "- (WHISPERING) Hi."}, []string{"- Hello.", "- Hi."}},
+		{[]string{"♪ Happy birthday to you ♪"}, []string{}},
+		{[]string{"♪ Happy birthday", "to you ♪", "Make a wish!"}, []string{"Make a wish!"}},
+		{[]string{"(GRUNTS", "HEAVILY) Help me."}, []string{"Help me."}},
+		{[]string{"Note: this is not a label"}, []string{"Note: this is not a label"}},
+		{[]string{"I said (and I mean it", "you can't stay here."}, []string{"I said (and I mean it", "you can't stay here."}},
+		{[]string{"[door slams", "Who's there?"}, []string{"[door slams", "Who's there?"}},
+		{[]string{"♪ Happy birthday", "Make a wish!"}, []string{"♪ Happy birthday", "Make a wish!"}},
+		{[]string{"(SIGHS) Fine (whatever", "Let's go."}, []string{"Fine (whatever", "Let's go."}},
+	}
+
+	for i, c := range cases {
+		actual := stripSDHLines(c.lines)
+		if len(actual) != len(c.expected) {
+			t.Errorf("Expected %d lines (case %d), got %d: %q", len(c.expected), i, len(actual), actual)
+			continue
+		}
+
+		for j, line := range c.expected {
+			if actual[j] != line {
+				t.Errorf("Expected line %d to be '%s' (case %d), got '%s'", j+1, line, i, actual[j])
+			}
+		}
+	}
+}
+
+func TestSubtitleFileStripSDH(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Text: []string{"[THUNDER RUMBLING]"}},
+			{Index: 2, Text: []string{"JOHN: Is anybody there?"}},
+			{Index: 3, Text: []string{"(FOOTSTEPS APPROACHING)", "♪ ♪"}},
+			{Index: 4, Text: []string{"It's me."}},
+		},
+	}
+
+	subtitle.StripSDH()
+
+	if len(subtitle.Entries) != 2 {
+		t.Fatalf("Expected 2 entries to remain, got %d", len(subtitle.Entries))
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "0s", "0s", "Is anybody there?")
+	assertEntry(t, subtitle.Entries[1], 2, "0s", "0s", "It's me.")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SearchQuery is a single query of a bulk search.
+type SearchQuery struct {
+	// Text is the searched text.
+	Text string
+
+	// K is the maximal number of results of the query.
+	K int
+
+	// At and Radius restrict the query to entries near At, as described by SearchNear.
+	// If Radius is zero, the query isn't time restricted, as described by SearchCandidates.
+	At     time.Duration
+	Radius time.Duration
+}
+
+// BulkSearchResult holds the results of a single query of a bulk search,
+// as returned by SearchCandidates or SearchNear.
+type BulkSearchResult struct {
+	Results []*SearchResult
+	Err     error
+}
+
+// SearchBulk runs the given queries against the given index concurrently, using up to workers
+// goroutines. If workers isn't positive, the number of CPUs is used. The results are returned
+// in the order of the queries.
+func SearchBulk(index IndexedSubtitle, queries []SearchQuery, workers int) []BulkSearchResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(queries) {
+		workers = len(queries)
+	}
+
+	results := make([]BulkSearchResult, len(queries))
+	positions := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range positions {
+				results[i] = searchQuery(index, queries[i])
+			}
+		}()
+	}
+
+	for i := range queries {
+		positions <- i
+	}
+	close(positions)
+	wg.Wait()
+
+	return results
+}
+
+func searchQuery(index IndexedSubtitle, q SearchQuery) BulkSearchResult {
+	var result BulkSearchResult
+	if q.Radius > 0 {
+		result.Results, result.Err = index.SearchNear(q.Text, q.K, q.At, q.Radius)
+	} else {
+		result.Results, result.Err = index.SearchCandidates(q.Text, q.K)
+	}
+
+	return result
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+var benchmarkWords = strings.Fields(`the a man woman house car night day time road city river
+	light dark open close run walk talk fight love hate find lose keep take give money gun phone
+	door window friend brother sister father mother police doctor never always maybe tomorrow`)
+
+// newBenchmarkSubtitle generates a subtitle file with the given number of random entries.
+func newBenchmarkSubtitle(entries int) *SubtitleFile {
+	random := rand.New(rand.NewSource(1))
+	subtitle := &SubtitleFile{
+		Entries: make([]*SubtitleEntry, entries),
+	}
+
+	for i := range subtitle.Entries {
+		words := make([]string, 4+random.Intn(8))
+		for j := range words {
+			words[j] = benchmarkWords[random.Intn(len(benchmarkWords))]
+		}
+
+		start := time.Duration(i) * 3 * time.Second
+		subtitle.Entries[i] = &SubtitleEntry{
+			Index: i + 1,
+			Start: start,
+			End:   start + 2*time.Second,
+			Text:  []string{strings.Join(words, " ")},
+		}
+	}
+
+	return subtitle
+}
+
+func TestSearchBulk(t *testing.T) {
+	indexedSub, err := NewIndexedSubtitle(testSubtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	queries := []SearchQuery{
+		{Text: "happily ever after", K: 1},
+		{Text: "Once upon a time", K: 1},
+		{Text: "Nothing like this", K: 1},
+		{Text: "Something horrible", K: 3, At: mustParseDuration("1m20s"), Radius: mustParseDuration("1m")},
+		{Text: "happily ever after", K: 3, At: mustParseDuration("1m20s"), Radius: mustParseDuration("1m")},
+	}
+
+	for _, workers := range []int{0, 1, 2, 10} {
+		results := SearchBulk(indexedSub, queries, workers)
+		if len(results) != len(queries) {
+			t.Fatalf("Expected %d results (%d workers), got %d", len(queries), workers, len(results))
+		}
+
+		for i, index := range []int{3, 1, 0, 2, 0} {
+			if index == 0 {
+				if results[i].Err != ErrNoHits {
+					t.Errorf("Expected ErrNoHits for query %d (%d workers), got %v", i, workers, results[i].Err)
+				}
+				continue
+			}
+
+			if results[i].Err != nil {
+				t.Errorf("Got error for query %d (%d workers): %v", i, workers, results[i].Err)
+				continue
+			}
+
+			if results[i].Results[0].Entry.Index != index {
+				t.Errorf("Expected entry %d to be found for query %d (%d workers), got %v",
+					index, i, workers, results[i].Results[0].Entry)
+			}
+		}
+	}
+
+	if results := SearchBulk(indexedSub, nil, 4); len(results) != 0 {
+		t.Errorf("Expected no results for no queries, got %v", results)
+	}
+}
+
+func TestIndexedSubtitleSearchLarge(t *testing.T) {
+	subtitle := newBenchmarkSubtitle(2*indexBatchSize + 1)
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while indexing subtitle, got error: %v", err)
+	}
+
+	count, err := indexedSub.(*bleveIndexedSubtitle).index.DocCount()
+	if err != nil {
+		t.Fatalf("Got error while counting indexed entries: %v", err)
+	}
+
+	if count != uint64(len(subtitle.Entries)) {
+		t.Errorf("Expected %d entries to be indexed, got %d", len(subtitle.Entries), count)
+	}
+}
+
+func BenchmarkNewIndexedSubtitle(b *testing.B) {
+	subtitle := newBenchmarkSubtitle(2000)
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en"})
+			if err != nil {
+				b.Fatalf("Got error while indexing subtitle: %v", err)
+			}
+			indexedSub.Close()
+		}
+	})
+
+	// Index entries one by one, for comparison with batch indexing.
+	b.Run("sequential", func(b *testing.B) {
+		indexMapping, err := newEntryMapping("en")
+		if err != nil {
+			b.Fatalf("Got error while creating index mapping: %v", err)
+		}
+
+		for i := 0; i < b.N; i++ {
+			index, err := bleve.NewMemOnly(indexMapping)
+			if err != nil {
+				b.Fatalf("Got error while creating index: %v", err)
+			}
+
+			for j, entry := range subtitle.Entries {
+				err = index.Index(strconv.Itoa(j), &indexedEntry{
+					Text:  matchText(entry.Text),
+					Start: entry.Start.Seconds(),
+					End:   entry.End.Seconds(),
+				})
+				if err != nil {
+					b.Fatalf("Got error while indexing entry %d: %v", entry.Index, err)
+				}
+			}
+			index.Close()
+		}
+	})
+}
+
+func BenchmarkSearchBulk(b *testing.B) {
+	subtitle := newBenchmarkSubtitle(2000)
+	indexedSub, err := NewIndexedSubtitle(subtitle, IndexOptions{Language: "en"})
+	if err != nil {
+		b.Fatalf("Got error while indexing subtitle: %v", err)
+	}
+	defer indexedSub.Close()
+
+	queries := make([]SearchQuery, 200)
+	for i := range queries {
+		queries[i] = SearchQuery{Text: matchText(subtitle.Entries[i*10].Text), K: 3}
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				SearchBulk(indexedSub, queries, workers)
+			}
+		})
+	}
+}
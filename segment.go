@@ -0,0 +1,148 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	sentenceEndRegexp = regexp.MustCompile(`[.!?…]+["')\]]*\s+`)
+)
+
+// Merge joins consecutive entries which are separated by no more than maxGap, as long
+// as the text of the joined entry does not exceed maxLength characters.
+// The remaining entries are renumbered.
+func (f *SubtitleFile) Merge(maxGap time.Duration, maxLength int) {
+	if len(f.Entries) == 0 {
+		return
+	}
+
+	entries := make([]*SubtitleEntry, 0, len(f.Entries))
+	current := f.Entries[0]
+	for _, entry := range f.Entries[1:] {
+		gap := entry.Start - current.End
+		length := textLength(current.Text) + 1 + textLength(entry.Text)
+		if gap <= maxGap && length <= maxLength {
+			current = &SubtitleEntry{
+				Index: current.Index,
+				Start: current.Start,
+				End:   entry.End,
+				Text:  append(append(make([]string, 0, len(current.Text)+len(entry.Text)), current.Text...), entry.Text...),
+			}
+			continue
+		}
+
+		entries = append(entries, current)
+		current = entry
+	}
+
+	f.Entries = append(entries, current)
+	f.Renumber()
+}
+
+// Split splits entries whose text is longer than maxLength characters into several
+// consecutive entries, as described by SplitEntry. The resulting entries are renumbered.
+func (f *SubtitleFile) Split(maxLength int) {
+	entries := make([]*SubtitleEntry, 0, len(f.Entries))
+	for _, entry := range f.Entries {
+		if textLength(entry.Text) <= maxLength {
+			entries = append(entries, entry)
+			continue
+		}
+
+		entries = append(entries, SplitEntry(entry)...)
+	}
+
+	f.Entries = entries
+	f.Renumber()
+}
+
+// Resegment returns a copy of the subtitle file, with its entries merged and then split using the
+// default limits of the merge and split commands, so that subtitle files segmenting their dialogue
+// differently, e.g. in a single two-line entry rather than in two entries, are segmented alike.
+func (f *SubtitleFile) Resegment() *SubtitleFile {
+	resegmented := &SubtitleFile{
+		Entries: make([]*SubtitleEntry, len(f.Entries)),
+	}
+	for i, entry := range f.Entries {
+		copied := *entry
+		resegmented.Entries[i] = &copied
+	}
+
+	resegmented.Merge(defaultMergeMaxGap, defaultMergeMaxLength)
+	resegmented.Split(defaultSplitMaxLength)
+	return resegmented
+}
+
+// SplitEntry splits the given entry at dialogue turns, i.e. lines starting with a dash, and at
+// sentence boundaries within each turn, regardless of how sentences are broken into lines. The entry
+// duration is distributed among the parts in proportion to their length. An entry which cannot be
+// split is returned as is.
+func SplitEntry(entry *SubtitleEntry) []*SubtitleEntry {
+	var parts []string
+	for _, turn := range dialogueTurns(entry.Text) {
+		parts = append(parts, splitSentences(turn)...)
+	}
+
+	if len(parts) < 2 {
+		return []*SubtitleEntry{entry}
+	}
+
+	total := 0
+	for _, part := range parts {
+		total += utf8.RuneCountInString(part)
+	}
+	duration := entry.End - entry.Start
+
+	entries := make([]*SubtitleEntry, len(parts))
+	start := entry.Start
+	consumed := 0
+	for i, part := range parts {
+		consumed += utf8.RuneCountInString(part)
+		end := entry.Start + duration*time.Duration(consumed)/time.Duration(total)
+		if i == len(parts)-1 {
+			end = entry.End
+		}
+
+		entries[i] = &SubtitleEntry{
+			Index: entry.Index,
+			Start: start,
+			End:   end,
+			Text:  []string{part},
+		}
+		start = end
+	}
+
+	return entries
+}
+
+// splitSentences splits the given line into sentences, keeping their terminating punctuation.
+func splitSentences(line string) []string {
+	sentences := make([]string, 0, 2)
+	start := 0
+	for _, loc := range sentenceEndRegexp.FindAllStringIndex(line, -1) {
+		sentences = append(sentences, strings.TrimSpace(line[start:loc[1]]))
+		start = loc[1]
+	}
+
+	if rest := strings.TrimSpace(line[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+
+	return sentences
+}
+
+// textLength returns the number of characters in the given lines, when joined by spaces.
+func textLength(lines []string) int {
+	length := 0
+	for i, line := range lines {
+		if i > 0 {
+			length++
+		}
+		length += utf8.RuneCountInString(line)
+	}
+
+	return length
+}
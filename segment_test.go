@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubtitleFileMerge(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("11s"), Text: []string{"Where were you?"}},
+			{Index: 2, Start: mustParseDuration("11s200ms"), End: mustParseDuration("12s"), Text: []string{"At home."}},
+			{Index: 3, Start: mustParseDuration("15s"), End: mustParseDuration("16s"), Text: []string{"Alone?"}},
+			{Index: 4, Start: mustParseDuration("16s100ms"), End: mustParseDuration("18s"), Text: []string{"No, I was with my brother and his friends."}},
+		},
+	}
+
+	subtitle.Merge(mustParseDuration("500ms"), 30)
+
+	if len(subtitle.Entries) != 3 {
+		t.Fatalf("Expected 3 entries after merge, got %d", len(subtitle.Entries))
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "10s", "12s", "Where were you?", "At home.")
+	assertEntry(t, subtitle.Entries[1], 2, "15s", "16s", "Alone?")
+	assertEntry(t, subtitle.Entries[2], 3, "16s100ms", "18s", "No, I was with my brother and his friends.")
+}
+
+func TestSubtitleFileSplit(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("12s700ms"), Text: []string{"- Where were you?", "- At home."}},
+			{Index: 2, Start: mustParseDuration("15s"), End: mustParseDuration("16s"), Text: []string{"Alone?"}},
+			{Index: 3, Start: mustParseDuration("20s"), End: mustParseDuration("26s200ms"), Text: []string{"No. I was with my brother, okay?"}},
+		},
+	}
+
+	subtitle.Split(10)
+
+	if len(subtitle.Entries) != 5 {
+		t.Fatalf("Expected 5 entries after split, got %d", len(subtitle.Entries))
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "10s", "11s700ms", "- Where were you?")
+	assertEntry(t, subtitle.Entries[1], 2, "11s700ms", "12s700ms", "- At home.")
+	assertEntry(t, subtitle.Entries[2], 3, "15s", "16s", "Alone?")
+	assertEntry(t, subtitle.Entries[3], 4, "20s", "20s600ms", "No.")
+	assertEntry(t, subtitle.Entries[4], 5, "20s600ms", "26s200ms", "I was with my brother, okay?")
+}
+
+func TestSplitEntrySentences(t *testing.T) {
+	// Sentences are split regardless of how they're broken into lines
+	entry := &SubtitleEntry{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("14s200ms"),
+		Text: []string{"I told you. You never", "listen to me, do you?"}}
+
+	entries := SplitEntry(entry)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries after split, got %d", len(entries))
+	}
+
+	assertEntry(t, entries[0], 1, "10s", "11s100ms", "I told you.")
+	assertEntry(t, entries[1], 1, "11s100ms", "14s200ms", "You never listen to me, do you?")
+
+	// A single sentence broken into lines isn't split
+	entry = &SubtitleEntry{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("13s"),
+		Text: []string{"Once upon a time", "in a far away land"}}
+	if entries := SplitEntry(entry); len(entries) != 1 || entries[0] != entry {
+		t.Errorf("Expected a single sentence not to be split, got %v", entries)
+	}
+}
+
+func TestSplitEntryEmptyLines(t *testing.T) {
+	entry := &SubtitleEntry{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("12s"), Text: []string{"", ""}}
+
+	entries := SplitEntry(entry)
+	if len(entries) != 1 || entries[0] != entry {
+		t.Errorf("Expected an entry with no text not to be split, got %v", entries)
+	}
+}
+
+func TestSubtitleFileResegment(t *testing.T) {
+	// The same dialogue, segmented in two entries, and in a single entry
+	twoEntries := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("11s"), Text: []string{"Where were you?"}},
+			{Index: 2, Start: mustParseDuration("11s200ms"), End: mustParseDuration("13s"), Text: []string{"At home, with my brother", "and his friends."}},
+		},
+	}
+	oneEntry := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("13s"), Text: []string{"- Where were you?", "- At home, with my brother and his friends."}},
+		},
+	}
+
+	for _, subtitle := range []*SubtitleFile{twoEntries, oneEntry} {
+		original := copySubtitle(subtitle)
+		resegmented := subtitle.Resegment()
+		if !equalSubtitleFiles(original, subtitle) {
+			t.Errorf("Expected the resegmented subtitle file not to be modified")
+		}
+
+		if len(resegmented.Entries) != 2 {
+			t.Fatalf("Expected 2 entries after resegmenting, got %v", resegmented.Entries)
+		}
+		if start := resegmented.Entries[0].Start; start != mustParseDuration("10s") {
+			t.Errorf("Expected the first entry to start at 10s, got %v", start)
+		}
+		if text := resegmented.Entries[1].Text; len(text) != 1 || !strings.HasSuffix(text[0], "At home, with my brother and his friends.") {
+			t.Errorf("Expected the second entry to hold the second sentence, got %v", text)
+		}
+	}
+}
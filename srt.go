@@ -170,7 +170,6 @@ func timestamp(hours, minutes, seconds, millis string) time.Duration {
 
 // timestampString converts the given duration into an SRT style timestamp, i.e. "hh:mm:ss,iii".
 func timestampString(d time.Duration) string {
-	fmt.Println(d)
 	return fmt.Sprintf("%02d:%02d:%02d,%03d",
 		d/time.Hour,
 		(d%time.Hour)/time.Minute,
@@ -10,6 +10,7 @@ func TestSRTParserReadSingleEntry(t *testing.T) {
 	content := `1
 00:01:15,760 --> 00:01:17,479
 Entry 1 line 1
+Entry 1 line 2
 `
 
 	sub, err := (&SRTParser{}).Read(bytes.NewReader([]byte(content)))
@@ -158,7 +159,7 @@ func assertEntry(t *testing.T, entry *SubtitleEntry, index int, start, end strin
 
 	for i, line := range text {
 		if entry.Text[i] != line {
-			t.Errorf("Expected line %d to be '%s', got '%s'", line, entry.Text[i])
+			t.Errorf("Expected line %d to be '%s', got '%s'", i+1, line, entry.Text[i])
 		}
 	}
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"time"
 	"io"
 )
@@ -29,12 +31,41 @@ type SubtitleEntry struct {
 	Text  []string
 }
 
+// Renumber assigns consecutive indices to the subtitle entries, starting at 1.
+func (f *SubtitleFile) Renumber() {
+	for i, entry := range f.Entries {
+		entry.Index = i + 1
+	}
+}
+
+// Shift moves all subtitle entries by the given duration, which may be negative.
+// It fails, without modifying any entry, if an entry would be moved to before the start.
 func (f *SubtitleFile) Shift(duration time.Duration) error {
-	// TODO implement
+	for _, entry := range f.Entries {
+		if entry.Start+duration < 0 {
+			return fmt.Errorf("Shifting by %v moves entry %d to before the start", duration, entry.Index)
+		}
+	}
+
+	for _, entry := range f.Entries {
+		entry.Start += duration
+		entry.End += duration
+	}
+
 	return nil
 }
 
+// Scale multiplies the timestamps of all subtitle entries by the given factor, e.g. to convert
+// subtitles between video frame rates, using the ratio of the source and target frame rates.
 func (f *SubtitleFile) Scale(factor float32) error {
-	// TODO implement
+	if factor <= 0 {
+		return fmt.Errorf("Invalid scale factor: %v", factor)
+	}
+
+	for _, entry := range f.Entries {
+		entry.Start = time.Duration(math.Round(float64(entry.Start) * float64(factor)))
+		entry.End = time.Duration(math.Round(float64(entry.End) * float64(factor)))
+	}
+
 	return nil
 }
\ No newline at end of file
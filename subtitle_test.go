@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSubtitleFileScale(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("12s"), Text: []string{"Where were you?"}},
+			{Index: 2, Start: mustParseDuration("1h"), End: mustParseDuration("1h2s"), Text: []string{"At home."}},
+		},
+	}
+
+	err := subtitle.Scale(0.5)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while scaling subtitle, got error: %v", err)
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "5s", "6s", "Where were you?")
+	assertEntry(t, subtitle.Entries[1], 2, "30m", "30m1s", "At home.")
+}
+
+func TestSubtitleFileScaleInvalidFactor(t *testing.T) {
+	subtitle := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("10s"), End: mustParseDuration("12s"), Text: []string{"Where were you?"}},
+		},
+	}
+
+	for _, factor := range []float32{0, -1} {
+		if err := subtitle.Scale(factor); err == nil {
+			t.Errorf("Expected an error scaling by %v", factor)
+		}
+	}
+
+	assertEntry(t, subtitle.Entries[0], 1, "10s", "12s", "Where were you?")
+}
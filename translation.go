@@ -1,26 +1,129 @@
 package main
 
 import (
-	"github.com/kkdai/mstranslator"
+	"fmt"
+	"regexp"
 	"strings"
+	"time"
 )
 
+// Translator translates subtitle files.
 type Translator interface {
-	Translate(subtitle *SubtitleFile, from, to string) (*SubtitleFile, error)
+	// Translate returns a new subtitle file, with the text of each entry of the given subtitle
+	// translated from one language to another, along with a report of the translation.
+	// The given subtitle file isn't modified. Entries which couldn't be translated, up to the
+	// tolerated fraction, keep their original text and are listed by the report.
+	Translate(subtitle *SubtitleFile, from, to string) (*SubtitleFile, *TranslationReport, error)
 }
 
-func NewMicrosoftTranslator(clientID, clientSecret string) Translator {
-	return &microsoftTranslator{
-		client: mstranslator.NewClient(clientID, clientSecret),
+// TextTranslator is a client of a translation service, translating texts in bulk.
+type TextTranslator interface {
+	// TranslateTexts translates the given texts in a single request,
+	// returning their translations in the same order.
+	TranslateTexts(texts []string, from, to string) ([]string, error)
+}
+
+// TranslateOptions control how subtitle files are translated.
+type TranslateOptions struct {
+	// KeepOriginal keeps the original lines of each entry, followed by the translated text,
+	// for bilingual subtitles. Untranslated entries hold their original lines only once.
+	KeepOriginal bool
+
+	// Batch controls how entries are packed into translation requests.
+	Batch BatchOptions
+
+	// Retry controls how failed translation requests are retried.
+	Retry RetryOptions
+
+	// MaxUntranslated is the fraction of entries, between 0 and 1, which may fail to be translated
+	// without failing the translation of the subtitle file. Zero means any failure is fatal.
+	MaxUntranslated float64
+
+	// Cache, if not nil, is used by NewProviderTranslator to avoid translating texts again.
+	Cache *TranslationCache
+}
+
+// TranslationReport summarizes the translation of a subtitle file.
+type TranslationReport struct {
+	Entries int
+
+	// Untranslated are the indexes of the entries which couldn't be translated, as given by the
+	// subtitle file.
+	Untranslated []int
+
+	// Requests is the number of translation requests sent, including the retried ones.
+	Requests int
+	Retries  int
+}
+
+func (r *TranslationReport) String() string {
+	report := fmt.Sprintf("Translated %d of %d entries", r.Entries-len(r.Untranslated), r.Entries)
+	if len(r.Untranslated) > 0 {
+		indexes := make([]string, len(r.Untranslated))
+		for i, index := range r.Untranslated {
+			indexes[i] = fmt.Sprint(index)
+		}
+		report += fmt.Sprintf(", %d untranslated (%s)", len(r.Untranslated), strings.Join(indexes, ", "))
 	}
+
+	return report + fmt.Sprintf(", using %d requests and %d retries", r.Requests, r.Retries)
 }
 
-type microsoftTranslator struct {
-	client *mstranslator.Client
+// NewTranslator creates a Translator translating entries using the given client.
+func NewTranslator(client TextTranslator, options TranslateOptions) Translator {
+	return &subtitleTranslator{
+		client:  client,
+		options: options,
+		sleep:   time.Sleep,
+	}
 }
 
-func (t *microsoftTranslator) Translate(subtitle *SubtitleFile, from, to string) (*SubtitleFile, error) {
-	// TODO: Implement bulk translation using TranslateArray
+type subtitleTranslator struct {
+	client  TextTranslator
+	options TranslateOptions
+
+	// sleep waits before retrying failed requests.
+	sleep func(d time.Duration)
+}
+
+func (t *subtitleTranslator) Translate(subtitle *SubtitleFile, from, to string) (*SubtitleFile, *TranslationReport, error) {
+	if checker, ok := t.client.(languageChecker); ok {
+		err := checker.checkLanguages(from, to)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	texts := make([]string, len(subtitle.Entries))
+	styles := make([]entryStyle, len(subtitle.Entries))
+	for i, entry := range subtitle.Entries {
+		styles[i], texts[i] = splitStyle(strings.Join(entry.Text, " "))
+	}
+
+	maxFailures := int(t.options.MaxUntranslated * float64(len(texts)))
+	result := t.translateTexts(texts, from, to, maxFailures)
+
+	report := &TranslationReport{
+		Entries:  len(subtitle.Entries),
+		Requests: result.requests,
+		Retries:  result.retries,
+	}
+
+	var firstErr error
+	for i, err := range result.errs {
+		if err != nil {
+			report.Untranslated = append(report.Untranslated, subtitle.Entries[i].Index)
+			if firstErr == nil || firstErr == errTranslationAborted {
+				firstErr = err
+			}
+		}
+	}
+
+	if len(report.Untranslated) > maxFailures {
+		return nil, report, fmt.Errorf("Failed to translate %d of %d entries: %v",
+			len(report.Untranslated), len(subtitle.Entries), firstErr)
+	}
+
 	tSubtitle := &SubtitleFile{
 		Entries: make([]*SubtitleEntry, len(subtitle.Entries)),
 	}
@@ -29,22 +132,115 @@ func (t *microsoftTranslator) Translate(subtitle *SubtitleFile, from, to string)
 		tEntry := &SubtitleEntry{
 			Index: entry.Index,
 			Start: entry.Start,
-			End: entry.End,
-			Text: make([]string, 0, 1),
+			End:   entry.End,
+			Text:  make([]string, 0, len(entry.Text)+1),
 		}
 
-		text := strings.Join(entry.Text, " ")
-		tText, err := t.client.Translate(text, from, to)
-
-		if err != nil {
-			// TODO: better error handling, e.g. skip entries
-			// until a threshold is reached
-			return nil, err
+		untranslated := result.errs[i] != nil
+		if t.options.KeepOriginal || untranslated {
+			tEntry.Text = append(tEntry.Text, entry.Text...)
+		}
+		if !untranslated && result.translations[i] != "" {
+			tEntry.Text = append(tEntry.Text, styles[i].apply(result.translations[i]))
 		}
 
-		entry.Text = append(entry.Text, tText)
 		tSubtitle.Entries[i] = tEntry
 	}
 
-	return tSubtitle, nil
+	return tSubtitle, report, nil
+}
+
+var (
+	// styleTagRegexp matches SRT styling tags, e.g. "<i>" or "<font color=...>",
+	// and SSA override blocks, e.g. "{\an8}".
+	styleTagRegexp = regexp.MustCompile(`<\s*/?\s*[a-zA-Z][^>]*>|\{\\[^}]*\}`)
+)
+
+// entryStyle holds the styling tags applying to the whole text of an entry.
+type entryStyle struct {
+	prefix string
+	suffix string
+}
+
+// splitStyle separates the styling tags applying to the whole of the given text, i.e. leading and
+// trailing tags, from the text. Tags within the text, and unbalanced leading or trailing tags, are
+// removed, as they can't be carried over to the translation.
+func splitStyle(text string) (entryStyle, string) {
+	var leading, trailing []string
+	for {
+		text = strings.TrimSpace(text)
+		loc := styleTagRegexp.FindStringIndex(text)
+		if loc == nil || loc[0] != 0 {
+			break
+		}
+		leading = append(leading, text[:loc[1]])
+		text = text[loc[1]:]
+	}
+	for {
+		text = strings.TrimSpace(text)
+		locs := styleTagRegexp.FindAllStringIndex(text, -1)
+		if len(locs) == 0 || locs[len(locs)-1][1] != len(text) {
+			break
+		}
+		loc := locs[len(locs)-1]
+		trailing = append([]string{text[loc[0]:]}, trailing...)
+		text = text[:loc[0]]
+	}
+
+	style := entryStyle{}
+	for _, tag := range leading {
+		name := styleTagName(tag)
+		switch {
+		case name == "":
+			// SSA override blocks apply to the whole entry
+			style.prefix += tag
+		case !strings.HasPrefix(strings.TrimLeft(tag, "< "), "/") && containsTag(trailing, "/"+name):
+			style.prefix += tag
+		}
+	}
+	for _, tag := range trailing {
+		name := styleTagName(tag)
+		if strings.HasPrefix(name, "/") && containsTag(leading, name[1:]) {
+			style.suffix += tag
+		}
+	}
+
+	text = styleTagRegexp.ReplaceAllString(text, "")
+	return style, strings.Join(strings.Fields(text), " ")
+}
+
+// apply styles the given text.
+func (s entryStyle) apply(text string) string {
+	return s.prefix + text + s.suffix
+}
+
+// styleTagName returns the lower case name of the given styling tag, prefixed by a slash for closing
+// tags, e.g. "i" or "/font", or an empty string for SSA override blocks.
+func styleTagName(tag string) string {
+	if strings.HasPrefix(tag, "{") {
+		return ""
+	}
+
+	name := strings.Trim(tag, "<> ")
+	closing := strings.HasPrefix(name, "/")
+	name = strings.TrimLeft(name, "/ ")
+	if i := strings.IndexAny(name, " \t="); i >= 0 {
+		name = name[:i]
+	}
+
+	name = strings.ToLower(name)
+	if closing {
+		name = "/" + name
+	}
+	return name
+}
+
+// containsTag checks whether the given tags contain a tag of the given name.
+func containsTag(tags []string, name string) bool {
+	for _, tag := range tags {
+		if styleTagName(tag) == name {
+			return true
+		}
+	}
+	return false
 }
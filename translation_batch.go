@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// errTranslationAborted marks the texts which weren't sent, due to too many failures.
+	errTranslationAborted = errors.New("Translation aborted due to too many failures")
+)
+
+const (
+	defaultBatchMaxChars    = 10000
+	defaultBatchMaxItems    = 2000
+	defaultBatchParallelism = 4
+)
+
+// BatchOptions control how subtitle text is packed into translation requests.
+// Zero values select the defaults.
+type BatchOptions struct {
+	// MaxChars is the maximal total number of characters of the texts of a single request.
+	// A text longer than MaxChars is sent in a request of its own.
+	MaxChars int
+
+	// MaxItems is the maximal number of texts of a single request.
+	MaxItems int
+
+	// Parallelism is the maximal number of requests sent concurrently.
+	Parallelism int
+}
+
+func (o BatchOptions) withDefaults() BatchOptions {
+	if o.MaxChars <= 0 {
+		o.MaxChars = defaultBatchMaxChars
+	}
+	if o.MaxItems <= 0 {
+		o.MaxItems = defaultBatchMaxItems
+	}
+	if o.Parallelism <= 0 {
+		o.Parallelism = defaultBatchParallelism
+	}
+	return o
+}
+
+// textBatch is a range of texts [start, end) sent in a single request.
+type textBatch struct {
+	start, end int
+}
+
+// batchTexts packs the given texts into consecutive batches, bounded by the given
+// character and item limits.
+func batchTexts(texts []string, maxChars, maxItems int) []textBatch {
+	batches := make([]textBatch, 0, 1)
+	start, chars := 0, 0
+	for i, text := range texts {
+		length := utf8.RuneCountInString(text)
+		if i > start && (i-start >= maxItems || chars+length > maxChars) {
+			batches = append(batches, textBatch{start, i})
+			start, chars = i, 0
+		}
+		chars += length
+	}
+
+	if start < len(texts) {
+		batches = append(batches, textBatch{start, len(texts)})
+	}
+
+	return batches
+}
+
+// textTranslation is the outcome of translating texts in batches.
+type textTranslation struct {
+	// translations holds the translation of each text, or an empty string if it failed.
+	translations []string
+
+	// errs holds the error of each text which failed to be translated, or nil.
+	errs []error
+
+	mutex    sync.Mutex
+	failed   int
+	requests int
+	retries  int
+}
+
+// translateTexts translates the given texts in batches, sending up to Parallelism batches
+// concurrently. Empty texts aren't sent, and are translated to empty texts. Requests failing
+// with transient errors are retried. If a batch fails otherwise, its texts are translated one
+// by one, so that only the failing texts are left untranslated. Once more than maxFailures texts
+// fail, the remaining batches aren't sent.
+func (t *subtitleTranslator) translateTexts(texts []string, from, to string, maxFailures int) *textTranslation {
+	options := t.options.Batch.withDefaults()
+
+	// Only send non-empty texts, keeping track of their positions.
+	positions := make([]int, 0, len(texts))
+	sent := make([]string, 0, len(texts))
+	for i, text := range texts {
+		if text != "" {
+			positions = append(positions, i)
+			sent = append(sent, text)
+		}
+	}
+
+	result := &textTranslation{
+		translations: make([]string, len(texts)),
+		errs:         make([]error, len(texts)),
+	}
+
+	batches := batchTexts(sent, options.MaxChars, options.MaxItems)
+	pending := make(chan textBatch)
+	workers := options.Parallelism
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for batch := range pending {
+				if result.aborted(maxFailures) {
+					result.fail(positions[batch.start:batch.end], errTranslationAborted)
+					continue
+				}
+
+				translations, err := t.send(sent[batch.start:batch.end], from, to, result)
+				if err != nil && !isTransient(err) && batch.end-batch.start > 1 {
+					for i := batch.start; i < batch.end; i++ {
+						if result.aborted(maxFailures) {
+							result.fail(positions[i:batch.end], err)
+							break
+						}
+
+						translations, err := t.send(sent[i:i+1], from, to, result)
+						if err != nil {
+							result.fail(positions[i:i+1], err)
+							continue
+						}
+						result.translations[positions[i]] = translations[0]
+					}
+					continue
+				}
+
+				if err != nil {
+					result.fail(positions[batch.start:batch.end], err)
+					continue
+				}
+
+				for i, translation := range translations {
+					result.translations[positions[batch.start+i]] = translation
+				}
+			}
+		}()
+	}
+
+	for _, batch := range batches {
+		pending <- batch
+	}
+	close(pending)
+	wg.Wait()
+
+	return result
+}
+
+// send translates the given texts in a single request, retrying it on transient errors.
+func (t *subtitleTranslator) send(texts []string, from, to string, result *textTranslation) ([]string, error) {
+	retry := t.options.Retry.withDefaults()
+	for attempt := 0; ; attempt++ {
+		result.mutex.Lock()
+		result.requests++
+		if attempt > 0 {
+			result.retries++
+		}
+		result.mutex.Unlock()
+
+		translations, err := t.client.TranslateTexts(texts, from, to)
+		if err == nil && len(translations) != len(texts) {
+			err = fmt.Errorf("Expected %d translations, got %d", len(texts), len(translations))
+		}
+
+		if err == nil || !isTransient(err) || attempt >= retry.MaxRetries {
+			return translations, err
+		}
+
+		t.sleep(retry.backoff(attempt, err))
+	}
+}
+
+// fail marks the texts at the given positions as failed with the given error.
+func (r *textTranslation) fail(positions []int, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, i := range positions {
+		r.errs[i] = err
+	}
+	r.failed += len(positions)
+}
+
+// aborted checks whether more than maxFailures texts failed.
+func (r *textTranslation) aborted(maxFailures int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.failed > maxFailures
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// translationsBucket maps cache keys to their translation, prefixed by the time it was stored.
+	translationsBucket = []byte("translations")
+
+	// timesBucket maps the time each translation was stored, followed by its cache key, to nothing.
+	// It orders translations by age, for expiry and eviction.
+	timesBucket = []byte("times")
+
+	// metaBucket holds the number of cached translations under entriesKey, so that they needn't be
+	// counted on every Put.
+	metaBucket = []byte("meta")
+	entriesKey = []byte("entries")
+
+	// cacheKeySeparator separates the components of cache keys.
+	cacheKeySeparator = "\x00"
+)
+
+// CacheOptions control the lifetime of cached translations.
+type CacheOptions struct {
+	// TTL is the duration cached translations are used for. Zero means forever.
+	TTL time.Duration
+
+	// MaxEntries is the maximal number of cached translations, beyond which the oldest are evicted.
+	// Zero means unlimited.
+	MaxEntries int
+}
+
+// TranslationCache is a persistent cache of translated texts, keyed by the translation provider and
+// its backend, the language pair and the text.
+type TranslationCache struct {
+	db      *bolt.DB
+	options CacheOptions
+
+	// now returns the current time.
+	now func() time.Time
+}
+
+// CacheStats describes the content of a translation cache.
+type CacheStats struct {
+	Entries        int
+	ExpiredEntries int
+	Oldest, Newest time.Time
+
+	// Pairs counts the entries of each provider and language pair, given as "provider:from:to".
+	Pairs map[string]int
+
+	// Size is the size of the cache file, in bytes.
+	Size int64
+}
+
+// defaultTranslationCachePath returns the default path of the translation cache file,
+// within the user cache directory.
+func defaultTranslationCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "subsyncer", "translations.db")
+}
+
+// OpenTranslationCache opens the translation cache at the given path, creating it if needed.
+func OpenTranslationCache(path string, options CacheOptions) (*TranslationCache, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{translationsBucket, timesBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		// Caches created before the number of translations was kept have them counted once
+		metaB := tx.Bucket(metaBucket)
+		if metaB.Get(entriesKey) != nil {
+			return nil
+		}
+		return metaB.Put(entriesKey, countBytes(tx.Bucket(translationsBucket).Stats().KeyN))
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &TranslationCache{
+		db:      db,
+		options: options,
+		now:     time.Now,
+	}, nil
+}
+
+// Get looks up the translations of the given texts, returning the translation of each text,
+// and whether it was found. The backend identifies the service or dictionary used by the provider.
+func (c *TranslationCache) Get(provider, backend, from, to string, texts []string) ([]string, []bool, error) {
+	translations := make([]string, len(texts))
+	found := make([]bool, len(texts))
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(translationsBucket)
+		for i, text := range texts {
+			value := bucket.Get(cacheKey(provider, backend, from, to, text))
+			if value == nil || c.expired(value) {
+				continue
+			}
+
+			translations[i] = string(value[8:])
+			found[i] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return translations, found, nil
+}
+
+// Put stores the translations of the given texts, evicting expired and excess translations.
+func (c *TranslationCache) Put(provider, backend, from, to string, texts, translations []string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		translationsB, timesB, metaB := tx.Bucket(translationsBucket), tx.Bucket(timesBucket), tx.Bucket(metaBucket)
+		entries := int(binary.BigEndian.Uint64(metaB.Get(entriesKey)))
+
+		stored := timeBytes(c.now())
+		for i, text := range texts {
+			key := cacheKey(provider, backend, from, to, text)
+			if old := translationsB.Get(key); old != nil {
+				oldTime := append(make([]byte, 0, 8+len(key)), old[:8]...)
+				if err := timesB.Delete(append(oldTime, key...)); err != nil {
+					return err
+				}
+			} else {
+				entries++
+			}
+
+			value := append(append([]byte{}, stored...), translations[i]...)
+			if err := translationsB.Put(key, value); err != nil {
+				return err
+			}
+			if err := timesB.Put(append(append([]byte{}, stored...), key...), []byte{}); err != nil {
+				return err
+			}
+		}
+
+		evicted, err := c.evict(translationsB, timesB, entries)
+		if err != nil {
+			return err
+		}
+		return metaB.Put(entriesKey, countBytes(entries-evicted))
+	})
+}
+
+// evict deletes the expired translations, and the oldest ones beyond the maximal number of entries,
+// given the number of cached translations. It returns the number of deleted translations.
+func (c *TranslationCache) evict(translationsB, timesB *bolt.Bucket, entries int) (int, error) {
+	excess := 0
+	if c.options.MaxEntries > 0 {
+		excess = entries - c.options.MaxEntries
+	}
+
+	var expiredBefore []byte
+	if c.options.TTL > 0 {
+		expiredBefore = timeBytes(c.now().Add(-c.options.TTL))
+	}
+
+	if excess <= 0 && expiredBefore == nil {
+		return 0, nil
+	}
+
+	// Deleting while iterating skips entries, so collect the keys first.
+	evicted := make([][]byte, 0)
+	cursor := timesB.Cursor()
+	for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+		if len(evicted) >= excess && (expiredBefore == nil || bytes.Compare(k[:8], expiredBefore) >= 0) {
+			break
+		}
+		evicted = append(evicted, append([]byte{}, k...))
+	}
+
+	for _, k := range evicted {
+		if err := timesB.Delete(k); err != nil {
+			return 0, err
+		}
+		if err := translationsB.Delete(k[8:]); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(evicted), nil
+}
+
+// Stats computes statistics of the cached translations.
+func (c *TranslationCache) Stats() (*CacheStats, error) {
+	stats := &CacheStats{
+		Pairs: make(map[string]int),
+	}
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		stats.Size = tx.Size()
+		return tx.Bucket(translationsBucket).ForEach(func(k, v []byte) error {
+			stats.Entries++
+			if c.expired(v) {
+				stats.ExpiredEntries++
+			}
+
+			stored := time.Unix(0, int64(binary.BigEndian.Uint64(v[:8])))
+			if stats.Oldest.IsZero() || stored.Before(stats.Oldest) {
+				stats.Oldest = stored
+			}
+			if stored.After(stats.Newest) {
+				stats.Newest = stored
+			}
+
+			components := strings.SplitN(string(k), cacheKeySeparator, 5)
+			if len(components) == 5 {
+				stats.Pairs[strings.Join([]string{components[0], components[2], components[3]}, ":")]++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// Clear deletes all cached translations.
+func (c *TranslationCache) Clear() error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{translationsBucket, timesBucket} {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(entriesKey, countBytes(0))
+	})
+}
+
+// Close closes the cache file.
+func (c *TranslationCache) Close() error {
+	return c.db.Close()
+}
+
+// expired checks whether the given cached value is older than the TTL.
+func (c *TranslationCache) expired(value []byte) bool {
+	if c.options.TTL <= 0 {
+		return false
+	}
+
+	stored := time.Unix(0, int64(binary.BigEndian.Uint64(value[:8])))
+	return c.now().Sub(stored) > c.options.TTL
+}
+
+// pairNames returns the provider and language pairs of the given stats, in alphabetical order.
+func (s *CacheStats) pairNames() []string {
+	names := make([]string, 0, len(s.Pairs))
+	for name := range s.Pairs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// cacheKey computes the key of the translation of the given text. Texts are normalized by
+// collapsing whitespace, and language codes by case.
+func cacheKey(provider, backend, from, to, text string) []byte {
+	return []byte(strings.Join([]string{
+		provider,
+		backend,
+		strings.ToLower(from),
+		strings.ToLower(to),
+		strings.Join(strings.Fields(text), " "),
+	}, cacheKeySeparator))
+}
+
+// timeBytes encodes the given time, so that encoded times sort chronologically.
+func timeBytes(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	return b
+}
+
+// countBytes encodes the given number of cached translations.
+func countBytes(n int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+// cachedTextTranslator is a TextTranslator translating only texts missing from a translation cache.
+type cachedTextTranslator struct {
+	client   TextTranslator
+	cache    *TranslationCache
+	provider string
+	backend  string
+}
+
+// NewCachedTextTranslator wraps the given client of the named provider, so that translations are
+// looked up in the given cache first, and new translations are added to it. The backend identifies
+// the service or dictionary used by the client, as given by providerBackend.
+func NewCachedTextTranslator(client TextTranslator, cache *TranslationCache, provider, backend string) TextTranslator {
+	return &cachedTextTranslator{
+		client:   client,
+		cache:    cache,
+		provider: provider,
+		backend:  backend,
+	}
+}
+
+func (c *cachedTextTranslator) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	translations, found, err := c.cache.Get(c.provider, c.backend, from, to, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make([]string, 0, len(texts))
+	positions := make([]int, 0, len(texts))
+	for i, text := range texts {
+		if !found[i] {
+			missing = append(missing, text)
+			positions = append(positions, i)
+		}
+	}
+
+	if len(missing) == 0 {
+		return translations, nil
+	}
+
+	tMissing, err := c.client.TranslateTexts(missing, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tMissing) != len(missing) {
+		return nil, fmt.Errorf("Expected %d translations, got %d", len(missing), len(tMissing))
+	}
+
+	err = c.cache.Put(c.provider, c.backend, from, to, missing, tMissing)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, translation := range tMissing {
+		translations[positions[i]] = translation
+	}
+
+	return translations, nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTestTranslationCache opens a translation cache in a new temporary directory,
+// returning it along with a function removing it.
+func openTestTranslationCache(t *testing.T, options CacheOptions) (*TranslationCache, func()) {
+	dir, err := ioutil.TempDir("", "subsyncer-cache")
+	if err != nil {
+		t.Fatalf("Failed to create cache directory: %v", err)
+	}
+
+	cache, err := OpenTranslationCache(filepath.Join(dir, "translations.db"), options)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Expected no error to occur while opening the translation cache, got error: %v", err)
+	}
+
+	return cache, func() {
+		cache.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCachedTextTranslator(t *testing.T) {
+	cache, remove := openTestTranslationCache(t, CacheOptions{})
+	defer remove()
+
+	client := &fakeTextTranslator{}
+	translator := NewTranslator(NewCachedTextTranslator(client, cache, "fake", ""), TranslateOptions{})
+
+	for i := 0; i < 2; i++ {
+		tSubtitle, _, err := translator.Translate(testSubtitle, "en", "fr")
+		if err != nil {
+			t.Fatalf("Expected no error to occur while translating (run %d), got error: %v", i, err)
+		}
+
+		expected := "ONCE UPON A TIME IN A FAR AWAY LAND"
+		if tSubtitle.Entries[0].Text[0] != expected {
+			t.Errorf("Expected entry 1 to be translated to '%s' (run %d), got %v", expected, i, tSubtitle.Entries[0].Text)
+		}
+	}
+
+	if len(client.requests) != 1 {
+		t.Errorf("Expected the texts to be translated once, got requests %v", client.requests)
+	}
+
+	// Texts are normalized, and only the missing ones are translated
+	texts := []string{"Once upon  a time\tin a far away land", "A new text"}
+	translations, err := NewCachedTextTranslator(client, cache, "fake", "").TranslateTexts(texts, "EN", "FR")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if len(client.requests) != 2 || len(client.requests[1]) != 1 || client.requests[1][0] != "A new text" {
+		t.Errorf("Expected only the missing text to be translated, got requests %v", client.requests)
+	}
+
+	if translations[0] != "ONCE UPON A TIME IN A FAR AWAY LAND" || translations[1] != "A NEW TEXT" {
+		t.Errorf("Expected the translations to be in the order of the texts, got %v", translations)
+	}
+
+	// Other providers, backends and language pairs are cached separately
+	_, err = NewCachedTextTranslator(client, cache, "other", "").TranslateTexts(texts[:1], "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+	_, err = NewCachedTextTranslator(client, cache, "fake", "http://localhost:5000").TranslateTexts(texts[:1], "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+	_, err = NewCachedTextTranslator(client, cache, "fake", "").TranslateTexts(texts[:1], "en", "de")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if len(client.requests) != 5 {
+		t.Errorf("Expected the texts to be translated again for another provider, backend and language pair, got requests %v", client.requests)
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatalf("Got error while computing cache stats: %v", err)
+	}
+
+	if stats.Entries != 7 || stats.Pairs["fake:en:fr"] != 5 || stats.Pairs["fake:en:de"] != 1 || stats.Pairs["other:en:fr"] != 1 {
+		t.Errorf("Expected 7 cache entries, 5 of which for fake:en:fr, got %+v", stats)
+	}
+
+	err = cache.Clear()
+	if err != nil {
+		t.Fatalf("Got error while clearing the cache: %v", err)
+	}
+
+	stats, _ = cache.Stats()
+	if stats.Entries != 0 {
+		t.Errorf("Expected no cache entries after clearing the cache, got %d", stats.Entries)
+	}
+}
+
+func TestTranslationCacheLimits(t *testing.T) {
+	cache, remove := openTestTranslationCache(t, CacheOptions{TTL: time.Hour, MaxEntries: 3})
+	defer remove()
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	for _, text := range []string{"one", "two", "three", "four"} {
+		now = now.Add(time.Minute)
+		err := cache.Put("fake", "", "en", "fr", []string{text}, []string{text + " (fr)"})
+		if err != nil {
+			t.Fatalf("Got error while caching '%s': %v", text, err)
+		}
+	}
+
+	// The oldest entry is evicted
+	_, found, err := cache.Get("fake", "", "en", "fr", []string{"one", "two", "four"})
+	if err != nil {
+		t.Fatalf("Got error while looking up the cache: %v", err)
+	}
+
+	if found[0] || !found[1] || !found[2] {
+		t.Errorf("Expected only the oldest entry to be evicted, got %v", found)
+	}
+
+	// Entries older than the TTL expire
+	now = now.Add(time.Hour - time.Minute + time.Second)
+	_, found, _ = cache.Get("fake", "", "en", "fr", []string{"two", "four"})
+	if found[0] || !found[1] {
+		t.Errorf("Expected only the expired entry not to be found, got %v", found)
+	}
+
+	stats, _ := cache.Stats()
+	if stats.Entries != 3 || stats.ExpiredEntries != 2 {
+		t.Errorf("Expected 3 cache entries, 2 of which expired, got %+v", stats)
+	}
+
+	err = cache.Put("fake", "", "en", "fr", []string{"five"}, []string{"five (fr)"})
+	if err != nil {
+		t.Fatalf("Got error while caching: %v", err)
+	}
+
+	stats, _ = cache.Stats()
+	if stats.Entries != 2 || stats.ExpiredEntries != 0 {
+		t.Errorf("Expected expired entries to be evicted, got %+v", stats)
+	}
+}
+
+func TestTranslationCacheEntriesCount(t *testing.T) {
+	cache, remove := openTestTranslationCache(t, CacheOptions{MaxEntries: 2})
+	defer remove()
+
+	put := func(cache *TranslationCache, texts ...string) {
+		for _, text := range texts {
+			err := cache.Put("fake", "", "en", "fr", []string{text}, []string{text + " (fr)"})
+			if err != nil {
+				t.Fatalf("Got error while caching '%s': %v", text, err)
+			}
+		}
+	}
+
+	// Replaced translations aren't counted again
+	put(cache, "one", "two", "two", "three")
+	_, found, _ := cache.Get("fake", "", "en", "fr", []string{"one", "two", "three"})
+	if found[0] || !found[1] || !found[2] {
+		t.Errorf("Expected only the oldest entry to be evicted, got %v", found)
+	}
+
+	// Caches with no count of their translations have them counted once opened
+	path := cache.db.Path()
+	err := cache.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Delete(entriesKey)
+	})
+	if err != nil {
+		t.Fatalf("Got error while deleting the count of translations: %v", err)
+	}
+	cache.Close()
+
+	cache, err = OpenTranslationCache(path, CacheOptions{MaxEntries: 2})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reopening the translation cache, got error: %v", err)
+	}
+	defer cache.Close()
+
+	put(cache, "four")
+	_, found, _ = cache.Get("fake", "", "en", "fr", []string{"two", "three", "four"})
+	if found[0] || !found[1] || !found[2] {
+		t.Errorf("Expected only the oldest entry to be evicted after reopening, got %v", found)
+	}
+
+	err = cache.Clear()
+	if err != nil {
+		t.Fatalf("Got error while clearing the cache: %v", err)
+	}
+
+	put(cache, "five", "six")
+	_, found, _ = cache.Get("fake", "", "en", "fr", []string{"five", "six"})
+	if !found[0] || !found[1] {
+		t.Errorf("Expected no entry to be evicted below the maximal number of entries after clearing, got %v", found)
+	}
+}
+
+func TestTranslationCacheInUse(t *testing.T) {
+	cache, remove := openTestTranslationCache(t, CacheOptions{})
+	defer remove()
+
+	// The lock is held per open file, so a second open within the process waits for it too
+	flags := translatorFlags{provider: "libre"}
+	flags.cache.path = cache.db.Path()
+
+	translator, err := flags.translator(TranslateOptions{})
+	if err != nil {
+		t.Fatalf("Expected the translator to fall back to translating uncached, got error: %v", err)
+	}
+	defer flags.close()
+
+	if translator == nil || flags.openCache != nil {
+		t.Errorf("Expected no translation cache to be opened while it's in use")
+	}
+
+	_, err = flags.cache.open()
+	if err != bolt.ErrTimeout {
+		t.Errorf("Expected opening a translation cache in use to time out, got %v", err)
+	}
+}
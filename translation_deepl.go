@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	deepLURL = "https://api.deepl.com"
+)
+
+var (
+	// deepLLanguages holds the ISO 639-1 codes of the languages supported by DeepL.
+	deepLLanguages = map[string]bool{
+		"ar": true, "bg": true, "cs": true, "da": true, "de": true, "el": true, "en": true, "es": true,
+		"et": true, "fi": true, "fr": true, "hu": true, "id": true, "it": true, "ja": true, "ko": true,
+		"lt": true, "lv": true, "nb": true, "nl": true, "pl": true, "pt": true, "ro": true, "ru": true,
+		"sk": true, "sl": true, "sv": true, "tr": true, "uk": true, "zh": true,
+	}
+)
+
+func init() {
+	registerTranslationProvider(&translationProvider{
+		name:         "deepl",
+		description:  "DeepL API",
+		batch:        BatchOptions{MaxChars: 30000, MaxItems: 50},
+		languageCode: deepLLanguageCode,
+		newClient: func(config ProviderConfig) (TextTranslator, error) {
+			if config.Key == "" {
+				return nil, fmt.Errorf("The deepl translator requires an API key")
+			}
+
+			return &deepLClient{
+				url:    providerURL(config, deepLURL),
+				key:    config.Key,
+				client: providerHTTPClient(config),
+			}, nil
+		},
+	})
+}
+
+// deepLClient is a TextTranslator using the DeepL API.
+type deepLClient struct {
+	url    string
+	key    string
+	client *http.Client
+}
+
+type deepLResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+func (c *deepLClient) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	form := url.Values{}
+	for _, text := range texts {
+		form.Add("text", text)
+	}
+	if from != "" {
+		form.Set("source_lang", strings.ToUpper(from))
+	}
+	form.Set("target_lang", strings.ToUpper(to))
+
+	request, err := http.NewRequest("POST", c.url+"/v2/translate", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "DeepL-Auth-Key "+c.key)
+
+	res := &deepLResponse{}
+	err = doJSONRequest(c.client, request, res)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make([]string, len(res.Translations))
+	for i, translation := range res.Translations {
+		translations[i] = translation.Text
+	}
+
+	return translations, nil
+}
+
+// deepLLanguageCode converts a language into its DeepL code: its upper case ISO 639-1 code, with the
+// regional variants of English and Portuguese, and the scripts of Chinese, selected for target languages.
+func deepLLanguageCode(language Language, source bool) (string, error) {
+	code := language.Code
+	if code == "no" {
+		code = "nb"
+	}
+
+	if !deepLLanguages[code] {
+		return "", unsupportedLanguage("deepl", language, source)
+	}
+
+	if !source {
+		switch {
+		case code == "en" && language.Region == "GB":
+			return "EN-GB", nil
+		case code == "en":
+			return "EN-US", nil
+		case code == "pt" && language.Region == "BR":
+			return "PT-BR", nil
+		case code == "pt":
+			return "PT-PT", nil
+		case language.TraditionalChinese():
+			return "ZH-HANT", nil
+		case code == "zh":
+			return "ZH-HANS", nil
+		}
+	}
+
+	return strings.ToUpper(code), nil
+}
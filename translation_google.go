@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	googleTranslateURL = "https://translation.googleapis.com"
+)
+
+func init() {
+	registerTranslationProvider(&translationProvider{
+		name:         "google",
+		description:  "Google Cloud Translation API (v2)",
+		batch:        BatchOptions{MaxChars: 5000, MaxItems: 128},
+		languageCode: googleLanguageCode,
+		newClient: func(config ProviderConfig) (TextTranslator, error) {
+			if config.Key == "" {
+				return nil, fmt.Errorf("The google translator requires an API key")
+			}
+
+			return &googleClient{
+				url:    providerURL(config, googleTranslateURL),
+				key:    config.Key,
+				client: providerHTTPClient(config),
+			}, nil
+		},
+	})
+}
+
+// googleClient is a TextTranslator using the Google Cloud Translation API.
+type googleClient struct {
+	url    string
+	key    string
+	client *http.Client
+}
+
+type googleRequest struct {
+	Q      []string `json:"q"`
+	Source string   `json:"source,omitempty"`
+	Target string   `json:"target"`
+	Format string   `json:"format"`
+}
+
+type googleResponse struct {
+	Data struct {
+		Translations []struct {
+			TranslatedText         string `json:"translatedText"`
+			DetectedSourceLanguage string `json:"detectedSourceLanguage"`
+		} `json:"translations"`
+	} `json:"data"`
+}
+
+type googleDetectRequest struct {
+	Q []string `json:"q"`
+}
+
+type googleDetectResponse struct {
+	Data struct {
+		Detections [][]struct {
+			Language   string  `json:"language"`
+			Confidence float64 `json:"confidence"`
+		} `json:"detections"`
+	} `json:"data"`
+}
+
+func (c *googleClient) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	payload, err := json.Marshal(&googleRequest{
+		Q:      texts,
+		Source: from,
+		Target: to,
+		Format: "text",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest("POST", c.url+"/language/translate/v2", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Goog-Api-Key", c.key)
+
+	res := &googleResponse{}
+	err = doJSONRequest(c.client, request, res)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make([]string, len(res.Data.Translations))
+	for i, translation := range res.Data.Translations {
+		translations[i] = translation.TranslatedText
+	}
+
+	return translations, nil
+}
+
+func (c *googleClient) DetectLanguage(texts []string) (string, error) {
+	payload, err := json.Marshal(&googleDetectRequest{
+		Q: []string{strings.Join(texts, "\n")},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest("POST", c.url+"/language/translate/v2/detect", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Goog-Api-Key", c.key)
+
+	res := &googleDetectResponse{}
+	err = doJSONRequest(c.client, request, res)
+	if err != nil {
+		return "", err
+	}
+
+	if len(res.Data.Detections) == 0 || len(res.Data.Detections[0]) == 0 {
+		return "", fmt.Errorf("No language detected")
+	}
+
+	return res.Data.Detections[0][0].Language, nil
+}
+
+// googleLanguageCode converts a language into its Google Cloud Translation code: its ISO 639-1 code,
+// except for Chinese, whose code selects between simplified and traditional characters.
+func googleLanguageCode(language Language, source bool) (string, error) {
+	switch {
+	case language.TraditionalChinese():
+		return "zh-TW", nil
+	case language.Code == "zh":
+		return "zh-CN", nil
+	default:
+		return language.Code, nil
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	libreTranslateURL = "https://libretranslate.com"
+)
+
+func init() {
+	registerTranslationProvider(&translationProvider{
+		name:         "libre",
+		description:  "LibreTranslate, or any compatible service",
+		batch:        BatchOptions{MaxChars: 5000, MaxItems: 100},
+		languageCode: libreLanguageCode,
+		newClient: func(config ProviderConfig) (TextTranslator, error) {
+			return &libreClient{
+				url:    providerURL(config, libreTranslateURL),
+				key:    config.Key,
+				client: providerHTTPClient(config),
+			}, nil
+		},
+	})
+}
+
+// libreClient is a TextTranslator using the LibreTranslate API.
+type libreClient struct {
+	url    string
+	key    string
+	client *http.Client
+}
+
+type libreRequest struct {
+	Q      []string `json:"q"`
+	Source string   `json:"source"`
+	Target string   `json:"target"`
+	Format string   `json:"format"`
+	APIKey string   `json:"api_key,omitempty"`
+}
+
+type libreResponse struct {
+	TranslatedText []string `json:"translatedText"`
+}
+
+type libreDetectRequest struct {
+	Q      string `json:"q"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type libreDetection struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+func (c *libreClient) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	source := from
+	if source == "" {
+		source = "auto"
+	}
+
+	payload, err := json.Marshal(&libreRequest{
+		Q:      texts,
+		Source: source,
+		Target: to,
+		Format: "text",
+		APIKey: c.key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest("POST", c.url+"/translate", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	res := &libreResponse{}
+	err = doJSONRequest(c.client, request, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.TranslatedText, nil
+}
+
+func (c *libreClient) DetectLanguage(texts []string) (string, error) {
+	payload, err := json.Marshal(&libreDetectRequest{
+		Q:      strings.Join(texts, "\n"),
+		APIKey: c.key,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest("POST", c.url+"/detect", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	// Detections are sorted by decreasing confidence
+	var res []libreDetection
+	err = doJSONRequest(c.client, request, &res)
+	if err != nil {
+		return "", err
+	}
+
+	if len(res) == 0 {
+		return "", fmt.Errorf("No language detected")
+	}
+
+	return res[0].Language, nil
+}
+
+// libreLanguageCode converts a language into its LibreTranslate code: its ISO 639-1 code, except for
+// Brazilian Portuguese and traditional Chinese, which have codes of their own.
+func libreLanguageCode(language Language, source bool) (string, error) {
+	switch {
+	case language.TraditionalChinese():
+		return "zt", nil
+	case language.Code == "pt" && language.Region == "BR":
+		return "pb", nil
+	default:
+		return language.Code, nil
+	}
+}
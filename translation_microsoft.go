@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkdai/mstranslator"
+)
+
+const (
+	translateArrayURL = mstranslator.ServiceURL + "TranslateArray"
+
+	// serializationArraysNamespace is the XML namespace of the texts of TranslateArray requests.
+	serializationArraysNamespace = "http://schemas.microsoft.com/2003/10/Serialization/Arrays"
+)
+
+func init() {
+	registerTranslationProvider(&translationProvider{
+		name:         "microsoft",
+		description:  "Microsoft Translator (legacy v2 API), with the client ID and secret as key and secret",
+		batch:        BatchOptions{MaxChars: 10000, MaxItems: 2000},
+		languageCode: microsoftLanguageCode,
+		newClient: func(config ProviderConfig) (TextTranslator, error) {
+			if config.Key == "" || config.Secret == "" {
+				return nil, fmt.Errorf("The microsoft translator requires a client ID and secret")
+			}
+
+			return newMicrosoftClient(config), nil
+		},
+	})
+}
+
+// newMicrosoftClient creates a TextTranslator using the Microsoft Translator TranslateArray API,
+// at the configured service and token URLs, or at the public ones if not configured. Access tokens
+// are only retrieved once translating.
+func newMicrosoftClient(config ProviderConfig) *microsoftClient {
+	endpoint := translateArrayURL
+	if config.URL != "" {
+		endpoint = providerURL(config, "") + "/TranslateArray"
+	}
+	authURL := config.AuthURL
+	if authURL == "" {
+		authURL = mstranslator.API_URL
+	}
+
+	client := providerHTTPClient(config)
+	tokens := &microsoftTokenSource{
+		authURL:      authURL,
+		clientID:     config.Key,
+		clientSecret: config.Secret,
+		region:       config.Region,
+		client:       client,
+		now:          time.Now,
+	}
+
+	return &microsoftClient{
+		endpoint: endpoint,
+		region:   config.Region,
+		token:    tokens.Token,
+		client:   client,
+	}
+}
+
+type microsoftClient struct {
+	endpoint string
+
+	// region is the region of the translator resource, sent along with requests if not empty.
+	region string
+
+	token  func() (string, error)
+	client *http.Client
+}
+
+// microsoftTokenSource retrieves access tokens using client credentials, reusing each token until
+// shortly before it expires. It's safe for concurrent use.
+type microsoftTokenSource struct {
+	authURL      string
+	clientID     string
+	clientSecret string
+	region       string
+	client       *http.Client
+
+	// now returns the current time.
+	now func() time.Time
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type microsoftTokenResponse struct {
+	AccessToken string `json:"access_token"`
+
+	// ExpiresIn is the lifetime of the token in seconds, given as a string by the legacy service.
+	ExpiresIn json.Number `json:"expires_in"`
+}
+
+// Token returns a valid access token, retrieving a new one if needed.
+func (s *microsoftTokenSource) Token() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != "" && s.now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", s.clientID)
+	form.Set("client_secret", s.clientSecret)
+	form.Set("scope", mstranslator.API_SCOPE)
+
+	request, err := http.NewRequest("POST", s.authURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setMicrosoftRegion(request, s.region)
+
+	result := &microsoftTokenResponse{}
+	err = doJSONRequest(s.client, request, result)
+	if err != nil {
+		return "", err
+	}
+
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("No access token received from %s", s.authURL)
+	}
+
+	// Renew tokens a minute early, so that they don't expire while requests are sent
+	lifetime, err := result.ExpiresIn.Int64()
+	if err != nil {
+		lifetime = 0
+	}
+	s.token = result.AccessToken
+	s.expires = s.now().Add(time.Duration(lifetime)*time.Second - time.Minute)
+
+	return s.token, nil
+}
+
+type translateArrayRequest struct {
+	XMLName xml.Name             `xml:"TranslateArrayRequest"`
+	AppID   string               `xml:"AppId"`
+	From    string               `xml:"From"`
+	Texts   []translateArrayText `xml:"Texts>string"`
+	To      string               `xml:"To"`
+}
+
+type translateArrayText struct {
+	Namespace string `xml:"xmlns,attr"`
+	Text      string `xml:",chardata"`
+}
+
+type translateArrayResponse struct {
+	XMLName   xml.Name `xml:"ArrayOfTranslateArrayResponse"`
+	Responses []struct {
+		TranslatedText string `xml:"TranslatedText"`
+	} `xml:"TranslateArrayResponse"`
+}
+
+// TranslateTexts translates the given texts in a single TranslateArray request.
+func (c *microsoftClient) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	req := &translateArrayRequest{
+		From:  from,
+		Texts: make([]translateArrayText, len(texts)),
+		To:    to,
+	}
+	for i, text := range texts {
+		req.Texts[i] = translateArrayText{Namespace: serializationArraysNamespace, Text: text}
+	}
+
+	payload, err := xml.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.token()
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "text/xml")
+	request.Header.Set("Authorization", "Bearer "+token)
+	setMicrosoftRegion(request, c.region)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, newTranslationError(response, body)
+	}
+
+	res := &translateArrayResponse{}
+	err = xml.Unmarshal(body, res)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make([]string, len(res.Responses))
+	for i, r := range res.Responses {
+		translations[i] = r.TranslatedText
+	}
+
+	return translations, nil
+}
+
+// setMicrosoftRegion sets the region of the translator resource the given request is sent to, if any.
+func setMicrosoftRegion(request *http.Request, region string) {
+	if region != "" {
+		request.Header.Set("Ocp-Apim-Subscription-Region", region)
+	}
+}
+
+// microsoftLanguageCode converts a language into its Microsoft Translator code: its ISO 639-1 code,
+// except for Chinese, whose code selects between simplified and traditional characters.
+func microsoftLanguageCode(language Language, source bool) (string, error) {
+	switch {
+	case language.TraditionalChinese():
+		return "zh-CHT", nil
+	case language.Code == "zh":
+		return "zh-CHS", nil
+	default:
+		return language.Code, nil
+	}
+}
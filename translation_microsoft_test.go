@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTranslateArrayServer starts a stand-in TranslateArray server, translating texts to upper case.
+// The number of texts of each received request is appended to batches.
+func newTranslateArrayServer(batches *[]int) *httptest.Server {
+	var mutex sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		req := &translateArrayRequest{}
+		if err := xml.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.From != "en" || req.To != "fr" {
+			http.Error(w, "Unexpected languages", http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		*batches = append(*batches, len(req.Texts))
+		mutex.Unlock()
+
+		fmt.Fprint(w, `<ArrayOfTranslateArrayResponse xmlns="http://schemas.datacontract.org/2004/07/Microsoft.MT.Web.Service.V2">`)
+		for _, text := range req.Texts {
+			fmt.Fprintf(w, "<TranslateArrayResponse><From>en</From><TranslatedText>%s</TranslatedText></TranslateArrayResponse>",
+				strings.ToUpper(text.Text))
+		}
+		fmt.Fprint(w, "</ArrayOfTranslateArrayResponse>")
+	}))
+}
+
+func newTestMicrosoftClient(endpoint, token string) *microsoftClient {
+	return &microsoftClient{
+		endpoint: endpoint,
+		token:    func() (string, error) { return token, nil },
+		client:   &http.Client{},
+	}
+}
+
+func TestMicrosoftTranslatorTranslate(t *testing.T) {
+	var batches []int
+	server := newTranslateArrayServer(&batches)
+	defer server.Close()
+
+	subtitle := &SubtitleFile{
+		Entries: make([]*SubtitleEntry, 10),
+	}
+	for i := range subtitle.Entries {
+		subtitle.Entries[i] = &SubtitleEntry{
+			Index: i + 1,
+			Start: mustParseDuration(fmt.Sprintf("%ds", i*3)),
+			End:   mustParseDuration(fmt.Sprintf("%ds", i*3+2)),
+			Text:  []string{fmt.Sprintf("Entry %d", i+1), "second line"},
+		}
+	}
+	subtitle.Entries[4].Text = []string{}
+
+	client := newTestMicrosoftClient(server.URL, "test-token")
+	translator := NewTranslator(client, TranslateOptions{Batch: BatchOptions{MaxItems: 4, Parallelism: 2}})
+	tSubtitle, _, err := translator.Translate(subtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	// The empty entry isn't sent
+	sort.Ints(batches)
+	if fmt.Sprint(batches) != "[1 4 4]" {
+		t.Errorf("Expected batches of 4, 4 and 1 texts, got %v", batches)
+	}
+
+	if len(tSubtitle.Entries) != len(subtitle.Entries) {
+		t.Fatalf("Expected %d translated entries, got %d", len(subtitle.Entries), len(tSubtitle.Entries))
+	}
+
+	for i, tEntry := range tSubtitle.Entries {
+		entry := subtitle.Entries[i]
+		expected := strings.ToUpper(strings.Join(entry.Text, " "))
+		if tEntry.Index != entry.Index || tEntry.Start != entry.Start || tEntry.End != entry.End {
+			t.Errorf("Expected translated entry %d to keep its index and times, got %v", entry.Index, tEntry)
+		}
+
+		if strings.Join(tEntry.Text, "\n") != expected {
+			t.Errorf("Expected entry %d to be translated to '%s', got '%s'", entry.Index, expected, tEntry.Text)
+		}
+	}
+
+	batches = nil
+	translator = NewTranslator(client, TranslateOptions{Batch: BatchOptions{MaxChars: 20}})
+	_, _, err = translator.Translate(subtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if len(batches) != 9 {
+		t.Errorf("Expected each text to be sent in a request of its own, got batches %v", batches)
+	}
+}
+
+func TestMicrosoftTranslatorTranslateError(t *testing.T) {
+	var batches []int
+	server := newTranslateArrayServer(&batches)
+	defer server.Close()
+
+	client := newTestMicrosoftClient(server.URL, "invalid-token")
+
+	_, _, err := NewTranslator(client, TranslateOptions{}).Translate(testSubtitle, "en", "fr")
+	if err == nil {
+		t.Errorf("Expected an error to occur while translating with an invalid token")
+	}
+}
+
+func TestMicrosoftTranslatorToken(t *testing.T) {
+	var batches []int
+	translateServer := newTranslateArrayServer(&batches)
+	defer translateServer.Close()
+
+	tokenRequests := 0
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if r.FormValue("client_id") != "client-id" || r.FormValue("client_secret") != "client-secret" ||
+			r.Header.Get("Ocp-Apim-Subscription-Region") != "westeurope" {
+			http.Error(w, "Invalid client credentials", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"token_type":"http://schemas.xmlsoap.org/ws/2009/11/swt-token-profile-1.0","access_token":"test-token","expires_in":"600"}`)
+	}))
+	defer authServer.Close()
+
+	// No token is retrieved until translating
+	translator, err := NewProviderTranslator("microsoft", ProviderConfig{
+		URL:     translateServer.URL,
+		AuthURL: authServer.URL,
+		Region:  "westeurope",
+		Key:     "client-id",
+		Secret:  "client-secret",
+	}, TranslateOptions{Batch: BatchOptions{MaxItems: 1}})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating the microsoft translator, got error: %v", err)
+	}
+
+	if tokenRequests != 0 {
+		t.Errorf("Expected no token to be retrieved before translating, got %d token requests", tokenRequests)
+	}
+
+	_, _, err = translator.Translate(testSubtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if tokenRequests != 1 || len(batches) != len(testSubtitle.Entries) {
+		t.Errorf("Expected a single token to be used for all %d batches, got %d token requests and batches %v",
+			len(testSubtitle.Entries), tokenRequests, batches)
+	}
+
+	// Failing to retrieve a token fails translation
+	authServer.Close()
+	client := newMicrosoftClient(ProviderConfig{URL: translateServer.URL, AuthURL: authServer.URL, Key: "client-id", Secret: "client-secret"})
+	_, err = client.TranslateTexts([]string{"Hello"}, "en", "fr")
+	if err == nil {
+		t.Errorf("Expected an error to occur while translating with an unreachable token service")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultProviderTimeout is the time limit of requests sent to translation services, unless
+	// configured otherwise.
+	defaultProviderTimeout = 30 * time.Second
+)
+
+// translationProvider is a translation service, selectable by name.
+type translationProvider struct {
+	name        string
+	description string
+
+	// batch holds the request limits of the service, used unless overridden by the translate options.
+	batch BatchOptions
+
+	// newClient creates a client of the service.
+	newClient func(config ProviderConfig) (TextTranslator, error)
+
+	// languageCode converts a language into the code used by the service, as the source language of
+	// translations if source is true, and as their target otherwise, failing if it isn't supported.
+	// If nil, ISO 639-1 codes are used.
+	languageCode func(language Language, source bool) (string, error)
+}
+
+// ProviderConfig configures the client of a translation provider.
+type ProviderConfig struct {
+	// URL is the base URL of the service. If empty, the public service of the provider is used.
+	URL string
+
+	// AuthURL is the URL access tokens are retrieved from, by services using client credentials.
+	// If empty, the public token service of the provider is used.
+	AuthURL string
+
+	// Region is the region of the service resource, for services deployed per region.
+	Region string
+
+	// Key is the API key of the service, or the client ID of services using client credentials.
+	Key string
+
+	// Secret is the client secret of services using client credentials.
+	Secret string
+
+	// Dictionary is the path of the bilingual dictionary file used by the dictionary provider.
+	Dictionary string
+
+	// RateLimit is the budget of requests sent to the service.
+	RateLimit RateLimit
+
+	// Timeout is the time limit of each request sent to the service, after which it's retried.
+	// If zero, defaultProviderTimeout is used.
+	Timeout time.Duration
+}
+
+const (
+	// defaultTranslationProvider is the translation provider used unless configured otherwise.
+	defaultTranslationProvider = "libre"
+)
+
+var (
+	translationProviders = make(map[string]*translationProvider)
+)
+
+// registerTranslationProvider makes the given translation provider available by its name.
+func registerTranslationProvider(provider *translationProvider) {
+	translationProviders[provider.name] = provider
+}
+
+// translationProviderNames returns the names of all registered translation providers, in alphabetical order.
+func translationProviderNames() []string {
+	names := make([]string, 0, len(translationProviders))
+	for name := range translationProviders {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// NewProviderTranslator creates a Translator using the named translation provider.
+func NewProviderTranslator(name string, config ProviderConfig, options TranslateOptions) (Translator, error) {
+	provider, ok := translationProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown translator: %s (available: %s)", name, strings.Join(translationProviderNames(), ", "))
+	}
+
+	client, err := provider.newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached translations don't count towards the rate limit.
+	client = NewRateLimitedTextTranslator(client, config.RateLimit)
+	if options.Cache != nil {
+		backend, err := providerBackend(config)
+		if err != nil {
+			return nil, err
+		}
+		client = NewCachedTextTranslator(client, options.Cache, name, backend)
+	}
+	client = &providerTextTranslator{client: client, provider: provider}
+
+	if options.Batch.MaxChars == 0 {
+		options.Batch.MaxChars = provider.batch.MaxChars
+	}
+	if options.Batch.MaxItems == 0 {
+		options.Batch.MaxItems = provider.batch.MaxItems
+	}
+
+	return NewTranslator(client, options), nil
+}
+
+// NewProviderDetector creates a LanguageDetector using the named translation provider,
+// if it supports language detection.
+func NewProviderDetector(name string, config ProviderConfig) (LanguageDetector, error) {
+	provider, ok := translationProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown translator: %s (available: %s)", name, strings.Join(translationProviderNames(), ", "))
+	}
+
+	client, err := provider.newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	detector, ok := client.(LanguageDetector)
+	if !ok {
+		return nil, fmt.Errorf("The %s translator doesn't support language detection", name)
+	}
+
+	return detector, nil
+}
+
+// languageCodes converts the given source and target languages, in any form accepted by ParseLanguage,
+// into the codes used by the provider. An empty source language, to be detected by the service, is kept.
+func (p *translationProvider) languageCodes(from, to string) (string, string, error) {
+	code := func(language string, source bool) (string, error) {
+		if language == "" && source {
+			return "", nil
+		}
+
+		parsed, err := ParseLanguage(language)
+		if err != nil {
+			return "", err
+		}
+
+		if p.languageCode == nil {
+			return parsed.Code, nil
+		}
+		return p.languageCode(parsed, source)
+	}
+
+	fromCode, err := code(from, true)
+	if err != nil {
+		return "", "", fmt.Errorf("Can't translate from %s to %s using the %s translator: %v", from, to, p.name, err)
+	}
+
+	toCode, err := code(to, false)
+	if err != nil {
+		return "", "", fmt.Errorf("Can't translate from %s to %s using the %s translator: %v", from, to, p.name, err)
+	}
+
+	return fromCode, toCode, nil
+}
+
+// unsupportedLanguage returns the error of a provider not supporting the given language.
+func unsupportedLanguage(provider string, language Language, source bool) error {
+	role := "target"
+	if source {
+		role = "source"
+	}
+
+	return fmt.Errorf("The %s translator doesn't support %s (%s) as a %s language", provider, language.Name(), language.Tag(), role)
+}
+
+// languageChecker is implemented by TextTranslators which can check whether they
+// support a language pair before translating.
+type languageChecker interface {
+	checkLanguages(from, to string) error
+}
+
+// providerTextTranslator is a TextTranslator converting language codes into the codes used by a provider.
+type providerTextTranslator struct {
+	client   TextTranslator
+	provider *translationProvider
+}
+
+func (t *providerTextTranslator) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	from, to, err := t.provider.languageCodes(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.client.TranslateTexts(texts, from, to)
+}
+
+func (t *providerTextTranslator) checkLanguages(from, to string) error {
+	_, _, err := t.provider.languageCodes(from, to)
+	return err
+}
+
+// providerURL returns the base URL configured for a provider, or its given default URL,
+// without a trailing slash.
+func providerURL(config ProviderConfig, defaultURL string) string {
+	if config.URL == "" {
+		return defaultURL
+	}
+
+	return strings.TrimRight(config.URL, "/")
+}
+
+// providerHTTPClient creates the HTTP client of a provider, with the configured request timeout,
+// so that stalled requests fail and are retried.
+func providerHTTPClient(config ProviderConfig) *http.Client {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultProviderTimeout
+	}
+
+	return &http.Client{Timeout: timeout}
+}
+
+// providerBackend identifies the service or dictionary translating texts for a provider with the
+// given config, so that translations of different backends are cached separately. Dictionaries are
+// identified by a hash of their content, as they may change in place.
+func providerBackend(config ProviderConfig) (string, error) {
+	backend := strings.TrimRight(config.URL, "/")
+	if config.Dictionary == "" {
+		return backend, nil
+	}
+
+	content, err := ioutil.ReadFile(config.Dictionary)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(content)
+	return backend + " " + hex.EncodeToString(hash[:16]), nil
+}
+
+// doJSONRequest sends the given translation request, and decodes its JSON response into result.
+func doJSONRequest(client *http.Client, request *http.Request, result interface{}) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return newTranslationError(response, body)
+	}
+
+	return json.Unmarshal(body, result)
+}
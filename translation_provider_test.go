@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testProviderTranslate translates testSubtitle from English to French using the given provider,
+// configured to use the given fake server, and verifies the text is translated to upper case.
+func testProviderTranslate(t *testing.T, provider string, config ProviderConfig, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	config.URL = server.URL + "/"
+	translator, err := NewProviderTranslator(provider, config, TranslateOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating the %s translator, got error: %v", provider, err)
+	}
+
+	tSubtitle, _, err := translator.Translate(testSubtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating using %s, got error: %v", provider, err)
+	}
+
+	for i, tEntry := range tSubtitle.Entries {
+		expected := strings.ToUpper(strings.Join(testSubtitle.Entries[i].Text, " "))
+		if len(tEntry.Text) != 1 || tEntry.Text[0] != expected {
+			t.Errorf("Expected entry %d to be translated to '%s' using %s, got %v",
+				tEntry.Index, expected, provider, tEntry.Text)
+		}
+	}
+}
+
+func TestLibreTranslator(t *testing.T) {
+	testProviderTranslate(t, "libre", ProviderConfig{Key: "libre-key"}, func(w http.ResponseWriter, r *http.Request) {
+		req := &libreRequest{}
+		err := json.NewDecoder(r.Body).Decode(req)
+		if err != nil || r.URL.Path != "/translate" {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		if req.APIKey != "libre-key" || req.Source != "en" || req.Target != "fr" {
+			http.Error(w, "Unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		res := &libreResponse{}
+		for _, text := range req.Q {
+			res.TranslatedText = append(res.TranslatedText, strings.ToUpper(text))
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+func TestDeepLTranslator(t *testing.T) {
+	testProviderTranslate(t, "deepl", ProviderConfig{Key: "deepl-key"}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/translate" || r.Header.Get("Authorization") != "DeepL-Auth-Key deepl-key" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if r.FormValue("source_lang") != "EN" || r.FormValue("target_lang") != "FR" {
+			http.Error(w, "Unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		translations := make([]string, 0, len(r.Form["text"]))
+		for _, text := range r.Form["text"] {
+			translations = append(translations, fmt.Sprintf(`{"detected_source_language":"EN","text":%q}`, strings.ToUpper(text)))
+		}
+		fmt.Fprintf(w, `{"translations":[%s]}`, strings.Join(translations, ","))
+	})
+}
+
+func TestGoogleTranslator(t *testing.T) {
+	testProviderTranslate(t, "google", ProviderConfig{Key: "google-key"}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/language/translate/v2" || r.URL.RawQuery != "" || r.Header.Get("X-Goog-Api-Key") != "google-key" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		req := &googleRequest{}
+		err := json.NewDecoder(r.Body).Decode(req)
+		if err != nil || req.Source != "en" || req.Target != "fr" {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		translations := make([]string, 0, len(req.Q))
+		for _, text := range req.Q {
+			translations = append(translations, fmt.Sprintf(`{"translatedText":%q}`, strings.ToUpper(text)))
+		}
+		fmt.Fprintf(w, `{"data":{"translations":[%s]}}`, strings.Join(translations, ","))
+	})
+}
+
+func TestProviderTranslatorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"Too many requests"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	for _, provider := range []string{"libre", "deepl", "google"} {
+		options := TranslateOptions{Retry: RetryOptions{InitialBackoff: time.Millisecond}}
+		translator, err := NewProviderTranslator(provider, ProviderConfig{URL: server.URL, Key: "key"}, options)
+		if err != nil {
+			t.Fatalf("Expected no error to occur while creating the %s translator, got error: %v", provider, err)
+		}
+
+		_, _, err = translator.Translate(testSubtitle, "en", "fr")
+		if err == nil || !strings.Contains(err.Error(), "Too many requests") {
+			t.Errorf("Expected the service error to be returned by %s, got %v", provider, err)
+		}
+	}
+}
+
+func TestProviderTranslatorTimeout(t *testing.T) {
+	// The first request stalls beyond the timeout, and is retried
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &libreRequest{}
+		json.NewDecoder(r.Body).Decode(req)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+
+		json.NewEncoder(w).Encode(&libreResponse{TranslatedText: req.Q})
+	}))
+	defer server.Close()
+
+	options := TranslateOptions{Retry: RetryOptions{InitialBackoff: time.Millisecond}}
+	config := ProviderConfig{URL: server.URL, Timeout: 100 * time.Millisecond}
+	translator, err := NewProviderTranslator("libre", config, options)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating the libre translator, got error: %v", err)
+	}
+
+	_, _, err = translator.Translate(testSubtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected the stalled request to be retried, got error: %v", err)
+	}
+
+	if requests := atomic.LoadInt32(&requests); requests != 2 {
+		t.Errorf("Expected 2 requests, the first timing out, got %d", requests)
+	}
+}
+
+func TestProviderDetector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/detect":
+			fmt.Fprint(w, `[{"confidence":90,"language":"fr"},{"confidence":10,"language":"it"}]`)
+		case "/language/translate/v2/detect":
+			fmt.Fprint(w, `{"data":{"detections":[[{"language":"fr","confidence":0.9,"isReliable":false}]]}}`)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, provider := range []string{"libre", "google"} {
+		detector, err := NewProviderDetector(provider, ProviderConfig{URL: server.URL, Key: "key"})
+		if err != nil {
+			t.Fatalf("Expected no error to occur while creating the %s detector, got error: %v", provider, err)
+		}
+
+		language, err := detector.DetectLanguage([]string{"Bonjour", "Au revoir"})
+		if err != nil || language != "fr" {
+			t.Errorf("Expected %s to detect fr, got %s (error: %v)", provider, language, err)
+		}
+	}
+
+	_, err := NewProviderDetector("deepl", ProviderConfig{Key: "key"})
+	if err == nil {
+		t.Errorf("Expected an error to occur while using the deepl translator for language detection")
+	}
+}
+
+func TestNewProviderTranslator(t *testing.T) {
+	_, err := NewProviderTranslator("unknown", ProviderConfig{}, TranslateOptions{})
+	if err == nil {
+		t.Errorf("Expected an error to occur while using an unknown translator")
+	}
+
+	for _, provider := range []string{"deepl", "google", "microsoft"} {
+		_, err = NewProviderTranslator(provider, ProviderConfig{}, TranslateOptions{})
+		if err == nil {
+			t.Errorf("Expected an error to occur while using the %s translator with no key", provider)
+		}
+	}
+
+	translator, err := NewProviderTranslator("deepl", ProviderConfig{Key: "key"}, TranslateOptions{Batch: BatchOptions{MaxChars: 100}})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating the deepl translator, got error: %v", err)
+	}
+
+	batch := translator.(*subtitleTranslator).options.Batch
+	if batch.MaxChars != 100 || batch.MaxItems != 50 {
+		t.Errorf("Expected the batch options to default to the limits of the provider, got %+v", batch)
+	}
+}
+
+func TestProviderBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subsyncer-dictionary")
+	if err != nil {
+		t.Fatalf("Failed to create dictionary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "en-fr.tsv")
+	backend := func(config ProviderConfig) string {
+		backend, err := providerBackend(config)
+		if err != nil {
+			t.Fatalf("Expected no error to occur while identifying the backend of %+v, got error: %v", config, err)
+		}
+		return backend
+	}
+
+	if backend(ProviderConfig{URL: "http://localhost:5000/"}) != backend(ProviderConfig{URL: "http://localhost:5000"}) {
+		t.Errorf("Expected a trailing slash not to change the backend")
+	}
+	if backend(ProviderConfig{URL: "http://localhost:5000"}) == backend(ProviderConfig{URL: "http://localhost:5001"}) {
+		t.Errorf("Expected different service URLs to be different backends")
+	}
+
+	ioutil.WriteFile(path, []byte("hello\tbonjour\n"), 0644)
+	first := backend(ProviderConfig{Dictionary: path})
+	ioutil.WriteFile(path, []byte("hello\tsalut\n"), 0644)
+	if backend(ProviderConfig{Dictionary: path}) == first {
+		t.Errorf("Expected a modified dictionary to be a different backend")
+	}
+
+	_, err = providerBackend(ProviderConfig{Dictionary: filepath.Join(dir, "missing.tsv")})
+	if err == nil {
+		t.Errorf("Expected an error to occur while identifying the backend of a missing dictionary")
+	}
+}
+
+func TestProviderTranslatorUnsupportedLanguages(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "Unexpected request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	translator, err := NewProviderTranslator("deepl", ProviderConfig{URL: server.URL, Key: "key"}, TranslateOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error to occur while creating the deepl translator, got error: %v", err)
+	}
+
+	_, _, err = translator.Translate(testSubtitle, "heb", "eng")
+	if err == nil || !strings.Contains(err.Error(), "doesn't support Hebrew (he) as a source language") {
+		t.Errorf("Expected an error to occur while translating from an unsupported language, got %v", err)
+	}
+
+	if requests != 0 {
+		t.Errorf("Expected no requests to be sent for an unsupported language pair, got %d", requests)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// TranslationError is returned when a translation service rejects a request.
+type TranslationError struct {
+	StatusCode int
+	Status     string
+	Message    string
+
+	// RetryAfter is the delay requested by the service before retrying, if any.
+	RetryAfter time.Duration
+}
+
+// newTranslationError creates a TranslationError for the given response, with the given body.
+func newTranslationError(response *http.Response, body []byte) *TranslationError {
+	err := &TranslationError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Message:    strings.TrimSpace(string(body)),
+	}
+
+	if seconds, parseErr := strconv.Atoi(response.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return err
+}
+
+func (e *TranslationError) Error() string {
+	return fmt.Sprintf("Translation request failed: %s: %s", e.Status, e.Message)
+}
+
+// Temporary checks whether the request may succeed if retried, i.e. whether the service
+// is rate limiting requests or failed internally.
+func (e *TranslationError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// isTransient checks whether the given translation error may not recur if the request is retried.
+func isTransient(err error) bool {
+	switch err := err.(type) {
+	case *TranslationError:
+		return err.Temporary()
+	case *url.Error:
+		return err.Timeout() || isTransientNetError(err.Err)
+	default:
+		return false
+	}
+}
+
+// isTransientNetError checks whether the given error of sending a request is a network failure which
+// may not recur, as opposed to e.g. an invalid URL or an unknown host.
+func isTransientNetError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.Timeout() || dnsErr.IsTemporary
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		// Failed to connect to the service, or the connection was reset
+		return true
+	}
+
+	// The connection was closed before the response was received
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryOptions control how failed translation requests are retried. Zero values select the defaults.
+type RetryOptions struct {
+	// MaxRetries is the maximal number of times a request is retried. Negative means no retries.
+	MaxRetries int
+
+	// InitialBackoff is the maximal delay before the first retry. The maximal delay doubles on
+	// each retry, up to MaxBackoff, and the actual delay is selected randomly up to it.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = defaultMaxRetries
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = defaultInitialBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultMaxBackoff
+	}
+	return o
+}
+
+// backoff computes the delay before the given retry, starting at zero, of a request which failed
+// with the given error. The delay is selected randomly up to the exponential backoff, to avoid
+// retrying concurrent requests together, but is at least the delay requested by the service.
+func (o RetryOptions) backoff(retry int, err error) time.Duration {
+	limit := o.InitialBackoff
+	for i := 0; i < retry && limit < o.MaxBackoff; i++ {
+		limit *= 2
+	}
+	if limit > o.MaxBackoff {
+		limit = o.MaxBackoff
+	}
+
+	delay := time.Duration(rand.Int63n(int64(limit) + 1))
+	if te, ok := err.(*TranslationError); ok && te.RetryAfter > delay {
+		delay = te.RetryAfter
+	}
+
+	return delay
+}
+
+// RateLimit is the budget of requests sent to a translation service. Zero values mean unlimited.
+type RateLimit struct {
+	RequestsPerSecond float64
+	CharsPerMinute    int
+}
+
+// rateLimiter delays translation requests to keep within a RateLimit.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	requests *tokenBucket
+	chars    *tokenBucket
+
+	// now returns the current time, and sleep waits for the given duration.
+	now   func() time.Time
+	sleep func(d time.Duration)
+}
+
+// newRateLimiter creates a rate limiter for the given limit, or returns nil if unlimited.
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.RequestsPerSecond <= 0 && limit.CharsPerMinute <= 0 {
+		return nil
+	}
+
+	l := &rateLimiter{
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+
+	if limit.RequestsPerSecond > 0 {
+		l.requests = newTokenBucket(limit.RequestsPerSecond, limit.RequestsPerSecond)
+	}
+	if limit.CharsPerMinute > 0 {
+		l.chars = newTokenBucket(float64(limit.CharsPerMinute)/60, float64(limit.CharsPerMinute))
+	}
+
+	return l
+}
+
+// wait blocks until a request of the given number of characters may be sent.
+func (l *rateLimiter) wait(chars int) {
+	l.sleep(l.reserve(chars))
+}
+
+// reserve reserves the budget of a request of the given number of characters,
+// returning the delay before it may be sent.
+func (l *rateLimiter) reserve(chars int) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	delay := time.Duration(0)
+	if l.requests != nil {
+		delay = l.requests.reserve(1, now)
+	}
+	if l.chars != nil {
+		if d := l.chars.reserve(float64(chars), now); d > delay {
+			delay = d
+		}
+	}
+
+	return delay
+}
+
+// rateLimitedTextTranslator is a TextTranslator delaying requests to keep within a rate limit.
+type rateLimitedTextTranslator struct {
+	client  TextTranslator
+	limiter *rateLimiter
+}
+
+// NewRateLimitedTextTranslator wraps the given client, so that its requests keep within the given
+// rate limit. If the limit is unlimited, the client is returned as is.
+func NewRateLimitedTextTranslator(client TextTranslator, limit RateLimit) TextTranslator {
+	limiter := newRateLimiter(limit)
+	if limiter == nil {
+		return client
+	}
+
+	return &rateLimitedTextTranslator{
+		client:  client,
+		limiter: limiter,
+	}
+}
+
+func (r *rateLimitedTextTranslator) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	chars := 0
+	for _, text := range texts {
+		chars += utf8.RuneCountInString(text)
+	}
+
+	r.limiter.wait(chars)
+	return r.client.TranslateTexts(texts, from, to)
+}
+
+// tokenBucket is a token bucket, refilled at a constant rate up to its capacity. Reservations
+// may overdraw the bucket, in which case they have to wait until it is refilled.
+type tokenBucket struct {
+	rate     float64 // tokens per second
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate, capacity float64) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+	}
+}
+
+// reserve takes n tokens from the bucket at the given time,
+// returning the delay until they are available.
+func (b *tokenBucket) reserve(n float64, now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
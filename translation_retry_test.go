@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyTextTranslator translates texts to upper case, failing the first requests with the
+// given transient error, and any request of a text containing "bad" with a permanent error.
+type flakyTextTranslator struct {
+	mutex    sync.Mutex
+	requests int
+	failures int
+	err      error
+}
+
+func (f *flakyTextTranslator) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.requests++
+	if f.requests <= f.failures {
+		return nil, f.err
+	}
+
+	translated := make([]string, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "bad") {
+			return nil, &TranslationError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+		}
+		translated[i] = strings.ToUpper(text)
+	}
+	return translated, nil
+}
+
+// newTestSubtitle creates a subtitle file of the given number of entries.
+func newTestSubtitle(entries int) *SubtitleFile {
+	subtitle := &SubtitleFile{
+		Entries: make([]*SubtitleEntry, entries),
+	}
+	for i := range subtitle.Entries {
+		subtitle.Entries[i] = &SubtitleEntry{
+			Index: i + 1,
+			Text:  []string{fmt.Sprintf("Entry %d", i+1)},
+		}
+	}
+	return subtitle
+}
+
+func TestTranslatorRetry(t *testing.T) {
+	client := &flakyTextTranslator{
+		failures: 2,
+		err:      &TranslationError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"},
+	}
+
+	var delays []time.Duration
+	translator := NewTranslator(client, TranslateOptions{}).(*subtitleTranslator)
+	translator.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	tSubtitle, report, err := translator.Translate(testSubtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if tSubtitle.Entries[0].Text[0] != "ONCE UPON A TIME IN A FAR AWAY LAND" {
+		t.Errorf("Expected entry 1 to be translated, got %v", tSubtitle.Entries[0].Text)
+	}
+
+	if report.Requests != 3 || report.Retries != 2 || len(delays) != 2 {
+		t.Errorf("Expected the request to be retried twice, got %d requests, %d retries and delays %v",
+			report.Requests, report.Retries, delays)
+	}
+
+	// Give up after the maximal number of retries
+	client = &flakyTextTranslator{failures: 10, err: client.err}
+	translator = NewTranslator(client, TranslateOptions{Retry: RetryOptions{MaxRetries: 1}}).(*subtitleTranslator)
+	translator.sleep = func(d time.Duration) {}
+
+	_, report, err = translator.Translate(testSubtitle, "en", "fr")
+	if err == nil || report.Requests != 2 {
+		t.Errorf("Expected translation to fail after a single retry, got error %v after %d requests", err, report.Requests)
+	}
+}
+
+func TestTranslatorPartialFailure(t *testing.T) {
+	subtitle := newTestSubtitle(10)
+	subtitle.Entries[2].Text = []string{"A bad entry"}
+
+	options := TranslateOptions{MaxUntranslated: 0.2, Batch: BatchOptions{MaxItems: 5}}
+	tSubtitle, report, err := NewTranslator(&flakyTextTranslator{}, options).Translate(subtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if fmt.Sprint(report.Untranslated) != "[3]" {
+		t.Errorf("Expected only entry 3 to be untranslated, got %v", report.Untranslated)
+	}
+
+	// The failing batch is translated entry by entry
+	if report.Requests != 7 {
+		t.Errorf("Expected 7 requests, got %d", report.Requests)
+	}
+
+	if tSubtitle.Entries[2].Text[0] != "A bad entry" || tSubtitle.Entries[3].Text[0] != "ENTRY 4" {
+		t.Errorf("Expected the untranslated entry to keep its original text, got %v", tSubtitle.Entries[2:4])
+	}
+
+	expected := "Translated 9 of 10 entries, 1 untranslated (3), using 7 requests and 0 retries"
+	if report.String() != expected {
+		t.Errorf("Expected report '%s', got '%s'", expected, report.String())
+	}
+
+	_, _, err = NewTranslator(&flakyTextTranslator{}, TranslateOptions{}).Translate(subtitle, "en", "fr")
+	if err == nil {
+		t.Errorf("Expected an error to occur while translating with no tolerated failures")
+	}
+}
+
+func TestTranslatorKeepOriginalDuplicateIndexes(t *testing.T) {
+	// Entries split in parts share their index
+	subtitle := newTestSubtitle(4)
+	for _, entry := range subtitle.Entries {
+		entry.Index = 1
+	}
+	subtitle.Entries[1].Text = []string{"A bad entry"}
+
+	options := TranslateOptions{KeepOriginal: true, MaxUntranslated: 0.5, Batch: BatchOptions{MaxItems: 1}}
+	bilingual, _, err := NewTranslator(&flakyTextTranslator{}, options).Translate(subtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	for i, entry := range bilingual.Entries {
+		expected := []string{subtitle.Entries[i].Text[0], strings.ToUpper(subtitle.Entries[i].Text[0])}
+		if i == 1 {
+			expected = expected[:1]
+		}
+
+		if fmt.Sprint(entry.Text) != fmt.Sprint(expected) {
+			t.Errorf("Expected bilingual entry %d to hold lines %v, got %v", i, expected, entry.Text)
+		}
+	}
+}
+
+func TestTranslatorAbort(t *testing.T) {
+	client := &flakyTextTranslator{
+		failures: 100,
+		err:      &TranslationError{StatusCode: http.StatusForbidden, Status: "403 Forbidden"},
+	}
+
+	options := TranslateOptions{MaxUntranslated: 0.1, Batch: BatchOptions{MaxItems: 5, Parallelism: 1}}
+	_, report, err := NewTranslator(client, options).Translate(newTestSubtitle(20), "en", "fr")
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Errorf("Expected the service error to be returned, got %v", err)
+	}
+
+	// A batch request, followed by 3 single entry requests, until more than 2 entries fail
+	if report.Requests != 4 || len(report.Untranslated) != 20 {
+		t.Errorf("Expected translation to be aborted after 4 requests, got %d requests and %d untranslated entries",
+			report.Requests, len(report.Untranslated))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	options := RetryOptions{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for retry, limit := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		for i := 0; i < 10; i++ {
+			if delay := options.backoff(retry, errors.New("Failure")); delay < 0 || delay > limit {
+				t.Errorf("Expected the delay of retry %d to be up to %v, got %v", retry, limit, delay)
+			}
+		}
+	}
+
+	err := &TranslationError{StatusCode: http.StatusTooManyRequests, RetryAfter: 10 * time.Second}
+	if delay := options.backoff(0, err); delay != 10*time.Second {
+		t.Errorf("Expected the delay requested by the service to be respected, got %v", delay)
+	}
+}
+
+// timeoutError is a net.Error of a timed out request.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		err       error
+		transient bool
+	}{
+		{&TranslationError{StatusCode: http.StatusTooManyRequests}, true},
+		{&TranslationError{StatusCode: http.StatusBadGateway}, true},
+		{&TranslationError{StatusCode: http.StatusUnauthorized}, false},
+		{&url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{&url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}, true},
+		{&url.Error{Op: "Post", URL: "http://localhost", Err: io.EOF}, true},
+		{&url.Error{Op: "Post", URL: "http://localhost", Err: timeoutError{}}, true},
+		{&url.Error{Op: "Post", URL: "http://unknown.invalid", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "unknown.invalid", IsNotFound: true}}}, false},
+		{&url.Error{Op: "Post", URL: "htp://localhost", Err: errors.New(`unsupported protocol scheme "htp"`)}, false},
+		{errors.New("Expected 2 translations, got 1"), false},
+	}
+
+	for _, c := range cases {
+		if isTransient(c.err) != c.transient {
+			t.Errorf("Expected error '%v' to be transient: %v", c.err, c.transient)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{RequestsPerSecond: 2, CharsPerMinute: 120})
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	cases := []struct {
+		elapsed time.Duration
+		chars   int
+		delay   time.Duration
+	}{
+		{0, 10, 0},
+		{0, 10, 0},
+		{0, 10, 500 * time.Millisecond},      // Out of requests
+		{time.Second, 100, 4 * time.Second},  // Out of characters
+		{10 * time.Second, 10, 0},            // Refilled
+		{time.Minute, 200, 40 * time.Second}, // Beyond the budget
+	}
+
+	for i, c := range cases {
+		now = now.Add(c.elapsed)
+		if delay := limiter.reserve(c.chars); delay != c.delay {
+			t.Errorf("Expected a delay of %v (case %d), got %v", c.delay, i, delay)
+		}
+	}
+
+	if newRateLimiter(RateLimit{}) != nil {
+		t.Errorf("Expected no rate limiter for an unlimited rate")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTextTranslator translates texts to upper case, recording the requests it receives.
+type fakeTextTranslator struct {
+	mutex    sync.Mutex
+	requests [][]string
+	err      error
+}
+
+func (f *fakeTextTranslator) TranslateTexts(texts []string, from, to string) ([]string, error) {
+	f.mutex.Lock()
+	f.requests = append(f.requests, append([]string{}, texts...))
+	f.mutex.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	translated := make([]string, len(texts))
+	for i, text := range texts {
+		translated[i] = strings.ToUpper(text)
+	}
+	return translated, nil
+}
+
+// copySubtitle creates a deep copy of the given subtitle file.
+func copySubtitle(subtitle *SubtitleFile) *SubtitleFile {
+	c := &SubtitleFile{
+		Entries: make([]*SubtitleEntry, len(subtitle.Entries)),
+	}
+	for i, entry := range subtitle.Entries {
+		c.Entries[i] = &SubtitleEntry{
+			Index: entry.Index,
+			Start: entry.Start,
+			End:   entry.End,
+			Text:  append([]string{}, entry.Text...),
+		}
+	}
+	return c
+}
+
+func TestBatchTexts(t *testing.T) {
+	texts := []string{"aaaa", "bbb", "cc", "d", "eeeeeeeeee", "f"}
+
+	cases := []struct {
+		maxChars, maxItems int
+		expected           []textBatch
+	}{
+		{100, 100, []textBatch{{0, 6}}},
+		{100, 4, []textBatch{{0, 4}, {4, 6}}},
+		{7, 100, []textBatch{{0, 2}, {2, 4}, {4, 5}, {5, 6}}},
+		{5, 2, []textBatch{{0, 1}, {1, 3}, {3, 4}, {4, 5}, {5, 6}}},
+	}
+
+	for i, c := range cases {
+		batches := batchTexts(texts, c.maxChars, c.maxItems)
+		if fmt.Sprint(batches) != fmt.Sprint(c.expected) {
+			t.Errorf("Expected batches %v (case %d), got %v", c.expected, i, batches)
+		}
+	}
+
+	if batches := batchTexts(nil, 10, 10); len(batches) != 0 {
+		t.Errorf("Expected no batches for no texts, got %v", batches)
+	}
+}
+
+func TestTranslatorTranslate(t *testing.T) {
+	original := copySubtitle(testSubtitle)
+	client := &fakeTextTranslator{}
+
+	tSubtitle, _, err := NewTranslator(client, TranslateOptions{}).Translate(testSubtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if len(client.requests) != 1 || len(client.requests[0]) != len(testSubtitle.Entries) {
+		t.Errorf("Expected all entries to be translated in a single request, got %v", client.requests)
+	}
+
+	if !equalSubtitleFiles(original, testSubtitle) {
+		t.Errorf("Expected the translated subtitle file not to be modified")
+	}
+
+	if len(tSubtitle.Entries) != len(testSubtitle.Entries) {
+		t.Fatalf("Expected %d translated entries, got %d", len(testSubtitle.Entries), len(tSubtitle.Entries))
+	}
+
+	for i, tEntry := range tSubtitle.Entries {
+		entry := testSubtitle.Entries[i]
+		if tEntry == entry {
+			t.Errorf("Expected translated entry %d to be a new entry", entry.Index)
+		}
+
+		expected := []string{strings.ToUpper(strings.Join(entry.Text, " "))}
+		if fmt.Sprint(tEntry.Text) != fmt.Sprint(expected) {
+			t.Errorf("Expected entry %d to be translated to %v, got %v", entry.Index, expected, tEntry.Text)
+		}
+	}
+}
+
+func TestTranslatorTranslateKeepOriginal(t *testing.T) {
+	original := copySubtitle(testSubtitle)
+
+	translator := NewTranslator(&fakeTextTranslator{}, TranslateOptions{KeepOriginal: true})
+	tSubtitle, _, err := translator.Translate(testSubtitle, "en", "fr")
+	if err != nil {
+		t.Fatalf("Expected no error to occur while translating, got error: %v", err)
+	}
+
+	if !equalSubtitleFiles(original, testSubtitle) {
+		t.Errorf("Expected the translated subtitle file not to be modified")
+	}
+
+	for i, tEntry := range tSubtitle.Entries {
+		entry := testSubtitle.Entries[i]
+		expected := append(append([]string{}, entry.Text...), strings.ToUpper(strings.Join(entry.Text, " ")))
+		if fmt.Sprint(tEntry.Text) != fmt.Sprint(expected) {
+			t.Errorf("Expected entry %d to be %v, got %v", entry.Index, expected, tEntry.Text)
+		}
+	}
+
+	// Modifying the translated entries doesn't modify the original ones
+	tSubtitle.Entries[0].Text[0] = "Modified"
+	if !equalSubtitleFiles(original, testSubtitle) {
+		t.Errorf("Expected the translated subtitle file not to share lines with the original")
+	}
+}
+
+func TestTranslatorTranslateError(t *testing.T) {
+	client := &fakeTextTranslator{err: errors.New("Service unavailable")}
+
+	tSubtitle, report, err := NewTranslator(client, TranslateOptions{}).Translate(testSubtitle, "en", "fr")
+	if err == nil || !strings.Contains(err.Error(), client.err.Error()) {
+		t.Errorf("Expected the client error to be returned, got %v", err)
+	}
+
+	if tSubtitle != nil {
+		t.Errorf("Expected no translated subtitle file to be returned on error, got %v", tSubtitle)
+	}
+
+	if len(report.Untranslated) != len(testSubtitle.Entries) {
+		t.Errorf("Expected all entries to be reported untranslated, got %v", report.Untranslated)
+	}
+}
+
+func equalSubtitleFiles(s1, s2 *SubtitleFile) bool {
+	if len(s1.Entries) != len(s2.Entries) {
+		return false
+	}
+
+	for i, entry := range s1.Entries {
+		if !equalSubtitleEntries(entry, s2.Entries[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestSplitStyle(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected string
+		plain    string
+	}{
+		{"Hello there", "HELLO THERE", "Hello there"},
+		{"<i>Hello there</i>", "<i>HELLO THERE</i>", "Hello there"},
+		{"<i>Hello</i> <i>there</i>", "<i>HELLO THERE</i>", "Hello there"},
+		{"{\\an8}<font color=\"#ffff00\"><b>Hello</b> there</font>", "{\\an8}<font color=\"#ffff00\">HELLO THERE</font>", "Hello there"},
+		{"<i>Hello</i> there", "HELLO THERE", "Hello there"},
+		{"Hello <b>there</b>", "HELLO THERE", "Hello there"},
+	}
+
+	for _, c := range cases {
+		style, plain := splitStyle(c.text)
+		if plain != c.plain {
+			t.Errorf("Expected the text of '%s' to be '%s', got '%s'", c.text, c.plain, plain)
+		}
+
+		if styled := style.apply(strings.ToUpper(plain)); styled != c.expected {
+			t.Errorf("Expected the styling of '%s' to be applied as '%s', got '%s'", c.text, c.expected, styled)
+		}
+	}
+}
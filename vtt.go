@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	vttTimestampRegexp = regexp.MustCompile(`^\s*(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})\s+-->\s+(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})`)
+
+	// vttUnsupportedTagRegexp matches SRT styling tags with no WebVTT counterpart:
+	// font tags and SSA override blocks.
+	vttUnsupportedTagRegexp = regexp.MustCompile(`(?i)<\s*/?\s*font[^>]*>|\{\\[^}]*\}`)
+)
+
+// VTTParser reads and writes subtitle files in WebVTT format.
+type VTTParser struct{}
+
+// Read the given stream until exhausted, and parse it as a WebVTT subtitle file.
+// Cue settings, and NOTE, STYLE and REGION blocks, are ignored.
+func (p *VTTParser) Read(reader io.Reader) (*SubtitleFile, error) {
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() || !strings.HasPrefix(strings.TrimPrefix(scanner.Text(), "\uFEFF"), "WEBVTT") {
+		if scanner.Err() != nil {
+			return nil, scanner.Err()
+		}
+		return nil, fmt.Errorf("Missing WEBVTT header")
+	}
+
+	// Skip the rest of the header, e.g. "Kind:" and "Language:" metadata
+	for scanner.Scan() && !isWhitespace(scanner.Text()) {
+	}
+
+	entries := make([]*SubtitleEntry, 0, initialEntriesCapacity)
+	for {
+		block, err := readVTTBlock(scanner)
+		if err != nil {
+			return nil, err
+		}
+
+		if block == nil {
+			break
+		}
+
+		entry, err := parseVTTCue(block, len(entries)+1)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return &SubtitleFile{
+		Entries: entries,
+	}, nil
+}
+
+// Write the given subtitle file into the given stream, in WebVTT format.
+func (p *VTTParser) Write(subtitle *SubtitleFile, writer io.Writer) error {
+	buffer := bufio.NewWriter(writer)
+
+	_, err := fmt.Fprintf(buffer, "WEBVTT\n")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range subtitle.Entries {
+		_, err = fmt.Fprintf(buffer, "\n%d\n%s --> %s\n", entry.Index, vttTimestampString(entry.Start), vttTimestampString(entry.End))
+		if err != nil {
+			return err
+		}
+
+		for _, line := range entry.Text {
+			// Blank lines would end the cue
+			line = vttUnsupportedTagRegexp.ReplaceAllString(line, "")
+			if isWhitespace(line) {
+				continue
+			}
+
+			_, err = fmt.Fprintf(buffer, "%s\n", line)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return buffer.Flush()
+}
+
+// readVTTBlock consumes the scanner until a full block of non-blank lines is read,
+// returning nil at the end of the stream.
+func readVTTBlock(scanner *bufio.Scanner) ([]string, error) {
+	var block []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isWhitespace(line) {
+			if len(block) > 0 {
+				break
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	return block, nil
+}
+
+// parseVTTCue parses the given block as a WebVTT cue, with the given index unless its identifier is
+// a number. It returns nil for NOTE, STYLE and REGION blocks.
+func parseVTTCue(block []string, index int) (*SubtitleEntry, error) {
+	switch strings.Fields(block[0])[0] {
+	case "NOTE", "STYLE", "REGION":
+		return nil, nil
+	}
+
+	// Skip the cue identifier, if any
+	if !strings.Contains(block[0], "-->") {
+		if i, err := parseIndex(block[0]); err == nil {
+			index = i
+		}
+		block = block[1:]
+		if len(block) == 0 {
+			return nil, fmt.Errorf("Incomplete WebVTT cue at index %d", index)
+		}
+	}
+
+	g := vttTimestampRegexp.FindStringSubmatch(block[0])
+	if g == nil {
+		return nil, fmt.Errorf("Invalid subtitle timestamp: %s", block[0])
+	}
+
+	return &SubtitleEntry{
+		Index: index,
+		Start: timestamp(g[1], g[2], g[3], g[4]),
+		End:   timestamp(g[5], g[6], g[7], g[8]),
+		Text:  block[1:],
+	}, nil
+}
+
+// vttTimestampString converts the given duration into a WebVTT style timestamp, i.e. "hh:mm:ss.iii".
+func vttTimestampString(d time.Duration) string {
+	return strings.Replace(timestampString(d), ",", ".", 1)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVTTParserRead(t *testing.T) {
+	content := `WEBVTT
+Kind: captions
+Language: en
+
+NOTE This is a comment
+spanning two lines
+
+STYLE
+::cue { color: yellow }
+
+intro
+00:01.000 --> 00:03.500 align:start
+<i>Entry 1 line 1</i>
+Entry 1 line 2
+
+7
+01:02:03.004 --> 01:02:05.000
+Entry 2
+`
+
+	sub, err := (&VTTParser{}).Read(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reading subtitle, got error: %v", err)
+	}
+
+	if len(sub.Entries) != 2 {
+		t.Fatalf("Expected 2 entries in subtitle, got %d", len(sub.Entries))
+	}
+
+	assertEntry(t, sub.Entries[0], 1, "1s", "3s500ms", "<i>Entry 1 line 1</i>", "Entry 1 line 2")
+	assertEntry(t, sub.Entries[1], 7, "1h2m3s4ms", "1h2m5s", "Entry 2")
+}
+
+func TestVTTParserReadMissingHeader(t *testing.T) {
+	content := `1
+00:00:01,000 --> 00:00:02,000
+Entry 1
+`
+
+	_, err := (&VTTParser{}).Read(strings.NewReader(content))
+	if err == nil {
+		t.Errorf("Expected an error reading a subtitle file without a WEBVTT header")
+	}
+}
+
+func TestVTTParserWrite(t *testing.T) {
+	sub := &SubtitleFile{
+		Entries: []*SubtitleEntry{
+			{Index: 1, Start: mustParseDuration("1s"), End: mustParseDuration("3s500ms"), Text: []string{`{\an8}<font color="red">Entry 1</font>`, "<i>line 2</i>"}},
+			{Index: 2, Start: mustParseDuration("1h2m3s4ms"), End: mustParseDuration("1h2m5s"), Text: []string{"Entry 2", "{\\i1}"}},
+		},
+	}
+
+	var buffer bytes.Buffer
+	err := (&VTTParser{}).Write(sub, &buffer)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while writing subtitle, got error: %v", err)
+	}
+
+	expected := `WEBVTT
+
+1
+00:00:01.000 --> 00:00:03.500
+Entry 1
+<i>line 2</i>
+
+2
+01:02:03.004 --> 01:02:05.000
+Entry 2
+`
+	if buffer.String() != expected {
+		t.Errorf("Expected written subtitle to be:\n%s\ngot:\n%s", expected, buffer.String())
+	}
+
+	read, err := (&VTTParser{}).Read(&buffer)
+	if err != nil {
+		t.Fatalf("Expected no error to occur while reading written subtitle, got error: %v", err)
+	}
+
+	if len(read.Entries) != 2 {
+		t.Fatalf("Expected 2 entries in written subtitle, got %d", len(read.Entries))
+	}
+
+	assertEntry(t, read.Entries[0], 1, "1s", "3s500ms", "Entry 1", "<i>line 2</i>")
+	assertEntry(t, read.Entries[1], 2, "1h2m3s4ms", "1h2m5s", "Entry 2")
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultMaxLineLength = 42
+	defaultMaxLines      = 2
+
+	overflowPenalty     = 1000
+	punctuationBonus    = 30
+	conjunctionBonus    = 20
+	functionWordPenalty = 40
+)
+
+var (
+	// conjunctions are words before which a line break reads naturally.
+	conjunctions = wordSet("and", "but", "or", "nor", "so", "because", "that", "which", "who", "when", "while", "if", "then", "than")
+
+	// functionWords are words after which a line break reads poorly.
+	functionWords = wordSet("a", "an", "the", "to", "of", "in", "on", "at", "for", "with", "from", "by", "my", "your", "his", "her", "our", "their", "i")
+
+	// breakPunctuation are characters after which a line break reads naturally.
+	breakPunctuation = ".,;:!?…。，、；：！？"
+
+	// cjkClosing are characters which must not start a line in CJK text.
+	cjkClosing = "、。，．！？」』）〉》】〕・ー…：；"
+
+	// cjkOpening are characters which must not end a line in CJK text.
+	cjkOpening = "「『（〈《【〔"
+
+	// bidiOpening are the bidirectional embedding, override and isolate initiators, and
+	// bidiClosing are the characters terminating them.
+	bidiOpening = "\u202A\u202B\u202D\u202E\u2066\u2067\u2068"
+	bidiClosing = "\u202C\u2069"
+)
+
+// wrapToken is a unit of text which is never broken across lines.
+type wrapToken struct {
+	text  string
+	space bool // whether the token is preceded by a space
+	width int
+}
+
+// Wrap reflows the text of all entries of the subtitle file into lines of at most
+// maxLineLength characters each, and at most maxLines lines per entry, as described by wrapLines.
+func (f *SubtitleFile) Wrap(maxLineLength, maxLines int) {
+	for _, entry := range f.Entries {
+		entry.Text = wrapLines(entry.Text, maxLineLength, maxLines)
+	}
+}
+
+// wrapLines reflows the given entry lines into balanced lines of at most maxLineLength characters,
+// and at most maxLines lines, preferring to break at punctuation and before conjunctions.
+// Each dialogue turn, i.e. a line starting with a dash, is kept on lines of its own, and the
+// lines of the entry are shared by its turns, going to the turns with the longest lines first.
+// Text which cannot be fit within the limits results in lines longer than maxLineLength, and
+// entries with more turns than maxLines in a line per turn.
+//
+// Line lengths are measured in display columns: CJK characters are counted as two columns,
+// while combining marks and bidirectional control characters (common in RTL text) are not counted.
+func wrapLines(lines []string, maxLineLength, maxLines int) []string {
+	turns := dialogueTurns(lines)
+
+	budgets := make([]int, len(turns))
+	wrappedTurns := make([][]string, len(turns))
+	for i, turn := range turns {
+		budgets[i] = 1
+		wrappedTurns[i] = wrapText(turn, maxLineLength, 1)
+	}
+
+	for spare := maxLines - len(turns); spare > 0; spare-- {
+		longest, widest := -1, maxLineLength
+		for i, wrappedTurn := range wrappedTurns {
+			if width := maxTextWidth(wrappedTurn); width > widest {
+				longest, widest = i, width
+			}
+		}
+		if longest < 0 {
+			break
+		}
+
+		budgets[longest]++
+		wrappedTurns[longest] = wrapText(turns[longest], maxLineLength, budgets[longest])
+	}
+
+	wrapped := make([]string, 0, maxLines)
+	for _, wrappedTurn := range wrappedTurns {
+		wrapped = append(wrapped, wrappedTurn...)
+	}
+
+	return wrapped
+}
+
+// dialogueTurns joins the given lines into a single text per dialogue turn. Lines which
+// do not start with a dash are considered continuations of the preceding turn.
+func dialogueTurns(lines []string) []string {
+	turns := make([]string, 0, 2)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if len(turns) == 0 || isDialogueLine(line) {
+			turns = append(turns, line)
+			continue
+		}
+
+		turns[len(turns)-1] += " " + line
+	}
+
+	return turns
+}
+
+// isDialogueLine determines whether the given line starts a dialogue turn, i.e. starts with a dash,
+// ignoring any leading bidirectional control characters.
+func isDialogueLine(line string) bool {
+	line = strings.TrimLeftFunc(line, func(r rune) bool {
+		return unicode.Is(unicode.Cf, r)
+	})
+
+	return strings.HasPrefix(line, "-") || strings.HasPrefix(line, "–")
+}
+
+// wrapText breaks the given text into balanced lines, as described by wrapLines.
+func wrapText(text string, maxLineLength, maxLines int) []string {
+	tokens := wrapTokens(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	if lineWidth(tokens) <= maxLineLength || maxLines < 2 {
+		return []string{joinTokens(tokens)}
+	}
+
+	var best [][]wrapToken
+	bestCost := math.Inf(1)
+	for n := 2; n <= maxLines && n <= len(tokens); n++ {
+		lines, cost := breakTokens(tokens, n, maxLineLength)
+		if cost < bestCost {
+			best, bestCost = lines, cost
+		}
+
+		if !overflows(lines, maxLineLength) {
+			break
+		}
+	}
+
+	if best == nil {
+		return []string{joinTokens(tokens)}
+	}
+
+	wrapped := make([]string, len(best))
+	for i, line := range best {
+		wrapped[i] = joinTokens(line)
+	}
+
+	return wrapped
+}
+
+// breakTokens finds the best way to break the given tokens into exactly n lines,
+// and returns the lines along with their cost.
+func breakTokens(tokens []wrapToken, n, maxLineLength int) ([][]wrapToken, float64) {
+	target := float64(lineWidth(tokens)) / float64(n)
+
+	// cost[k][j] is the cost of breaking tokens[:j] into k lines,
+	// and from[k][j] is where the last of these lines starts.
+	cost := make([][]float64, n+1)
+	from := make([][]int, n+1)
+	for k := range cost {
+		cost[k] = make([]float64, len(tokens)+1)
+		from[k] = make([]int, len(tokens)+1)
+		for j := range cost[k] {
+			cost[k][j] = math.Inf(1)
+		}
+	}
+	cost[0][0] = 0
+
+	for k := 1; k <= n; k++ {
+		for j := k; j <= len(tokens); j++ {
+			for i := k - 1; i < j; i++ {
+				if math.IsInf(cost[k-1][i], 1) {
+					continue
+				}
+
+				if i > 0 && !canBreakBefore(tokens, i) {
+					continue
+				}
+
+				c := cost[k-1][i] + lineCost(tokens[i:j], target, maxLineLength)
+				if j < len(tokens) {
+					c += breakCost(tokens, j)
+				}
+
+				if c < cost[k][j] {
+					cost[k][j] = c
+					from[k][j] = i
+				}
+			}
+		}
+	}
+
+	lines := make([][]wrapToken, n)
+	j := len(tokens)
+	for k := n; k > 0; k-- {
+		i := from[k][j]
+		lines[k-1] = tokens[i:j]
+		j = i
+	}
+
+	return lines, cost[n][len(tokens)]
+}
+
+// lineCost measures how far the given line is from the target width, penalizing overflow.
+func lineCost(line []wrapToken, target float64, maxLineLength int) float64 {
+	width := lineWidth(line)
+	c := math.Abs(float64(width) - target)
+	if width > maxLineLength {
+		c += float64(overflowPenalty * (width - maxLineLength))
+	}
+
+	return c
+}
+
+// breakCost measures how natural a line break before the token at index i is.
+func breakCost(tokens []wrapToken, i int) float64 {
+	prevText := strings.TrimRight(tokens[i-1].text, bidiClosing+" ")
+	nextText := strings.TrimLeft(tokens[i].text, bidiOpening+" ")
+	prev := strings.ToLower(strings.TrimRightFunc(prevText, unicode.IsPunct))
+	next := strings.ToLower(strings.TrimLeftFunc(nextText, unicode.IsPunct))
+
+	switch {
+	case strings.ContainsAny(lastRune(prevText), breakPunctuation):
+		return -punctuationBonus
+	case conjunctions[next]:
+		return -conjunctionBonus
+	case functionWords[prev]:
+		return functionWordPenalty
+	}
+
+	return 0
+}
+
+// canBreakBefore determines whether a line may start with the token at index i.
+func canBreakBefore(tokens []wrapToken, i int) bool {
+	if tokens[i].space {
+		return true
+	}
+
+	// Within CJK text, avoid breaking before closing or after opening punctuation.
+	return !strings.ContainsAny(firstRune(tokens[i].text), cjkClosing) &&
+		!strings.ContainsAny(lastRune(tokens[i-1].text), cjkOpening)
+}
+
+// wrapTokens splits the given text into tokens: words for space-separated scripts,
+// and single characters for CJK text, which may be broken between any two characters.
+func wrapTokens(text string) []wrapToken {
+	tokens := make([]wrapToken, 0, 16)
+	for i, field := range strings.Fields(text) {
+		space := i > 0
+		var word []rune
+		flush := func() {
+			if len(word) > 0 {
+				tokens = append(tokens, newWrapToken(string(word), space))
+				word, space = nil, false
+			}
+		}
+
+		for _, r := range field {
+			if isCJK(r) {
+				flush()
+				tokens = append(tokens, newWrapToken(string(r), space))
+				space = false
+				continue
+			}
+			word = append(word, r)
+		}
+		flush()
+	}
+
+	return attachBidiMarks(tokens)
+}
+
+// attachBidiMarks merges tokens made of bidirectional control characters alone into their
+// neighbors, so that a line break never separates them from the text they apply to: opening
+// marks are kept with the next token, and closing marks with the previous one.
+func attachBidiMarks(tokens []wrapToken) []wrapToken {
+	attached := make([]wrapToken, 0, len(tokens))
+	var opening *wrapToken
+	for i := range tokens {
+		token := tokens[i]
+		if opening != nil {
+			token = joinWrapTokens(*opening, token)
+			opening = nil
+		}
+
+		switch {
+		case strings.Trim(token.text, bidiOpening+" ") == "" && i < len(tokens)-1:
+			opening = &token
+		case strings.Trim(token.text, bidiOpening+bidiClosing+" ") == "" && len(attached) > 0:
+			attached[len(attached)-1] = joinWrapTokens(attached[len(attached)-1], token)
+		default:
+			attached = append(attached, token)
+		}
+	}
+
+	return attached
+}
+
+// joinWrapTokens joins the given consecutive tokens into a single token.
+func joinWrapTokens(first, second wrapToken) wrapToken {
+	text := first.text
+	if second.space {
+		text += " "
+	}
+
+	return newWrapToken(text+second.text, first.space)
+}
+
+func newWrapToken(text string, space bool) wrapToken {
+	return wrapToken{
+		text:  text,
+		space: space,
+		width: textWidth(text),
+	}
+}
+
+// joinTokens joins the given tokens into a line of text.
+func joinTokens(tokens []wrapToken) string {
+	var buffer strings.Builder
+	for i, token := range tokens {
+		if i > 0 && token.space {
+			buffer.WriteByte(' ')
+		}
+		buffer.WriteString(token.text)
+	}
+
+	return buffer.String()
+}
+
+// lineWidth returns the display width of the given tokens, when joined into a line.
+func lineWidth(tokens []wrapToken) int {
+	width := 0
+	for i, token := range tokens {
+		if i > 0 && token.space {
+			width++
+		}
+		width += token.width
+	}
+
+	return width
+}
+
+// overflows determines whether any of the given lines is wider than maxLineLength.
+func overflows(lines [][]wrapToken, maxLineLength int) bool {
+	for _, line := range lines {
+		if lineWidth(line) > maxLineLength {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxTextWidth returns the width of the widest of the given lines, as measured by textWidth.
+func maxTextWidth(lines []string) int {
+	width := 0
+	for _, line := range lines {
+		if w := textWidth(line); w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+// textWidth returns the display width of the given text.
+func textWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		case isWide(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+
+	return width
+}
+
+// isCJK determines whether the given rune belongs to a script written without spaces between words.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303F) || // CJK symbols and punctuation
+		(r >= 0xFF00 && r <= 0xFF60) // Fullwidth forms
+}
+
+// isWide determines whether the given rune is displayed as a full-width character.
+func isWide(r rune) bool {
+	return isCJK(r) || unicode.Is(unicode.Hangul, r)
+}
+
+func firstRune(s string) string {
+	for _, r := range s {
+		return string(r)
+	}
+	return ""
+}
+
+func lastRune(s string) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return ""
+	}
+	return string(r[len(r)-1])
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestWrapLines(t *testing.T) {
+	cases := []struct {
+		lines    []string
+		expected []string
+	}{
+		{
+			[]string{"Where were you?"},
+			[]string{"Where were you?"},
+		},
+		{
+			[]string{"Where", "were you?"},
+			[]string{"Where were you?"},
+		},
+		{
+			[]string{"I went to the store to buy some milk, but they were closed."},
+			[]string{"I went to the store to buy some milk,", "but they were closed."},
+		},
+		{
+			[]string{"I went to the store to buy some milk and", "some bread,", "but they were all closed for the day."},
+			[]string{"I went to the store to buy some milk", "and some bread,", "but they were all closed for the day."},
+		},
+		{
+			[]string{"- Where were you last night? We waited for hours.", "- At home."},
+			[]string{"- Where were you last night?", "We waited for hours.", "- At home."},
+		},
+		{
+			[]string{"- Where were you last night?", "- At home,", "with my brother."},
+			[]string{"- Where were you last night?", "- At home, with my brother."},
+		},
+		{
+			[]string{"我昨天晚上去了商店买牛奶，但是他们已经关门了。"},
+			[]string{"我昨天晚上去了商店买牛奶，", "但是他们已经关门了。"},
+		},
+		{
+			[]string{"‫הלכתי לחנות לקנות חלב, אבל היא כבר נסגרה.‬"},
+			[]string{"‫הלכתי לחנות לקנות חלב, אבל היא כבר נסגרה.‬"},
+		},
+	}
+
+	for i, c := range cases {
+		actual := wrapLines(c.lines, 42, 3)
+		if len(actual) != len(c.expected) {
+			t.Errorf("Expected %d lines (case %d), got %d: %q", len(c.expected), i, len(actual), actual)
+			continue
+		}
+
+		for j, line := range c.expected {
+			if actual[j] != line {
+				t.Errorf("Expected line %d to be '%s' (case %d), got '%s'", j+1, line, i, actual[j])
+			}
+		}
+	}
+}
+
+func TestWrapLinesRTLMarks(t *testing.T) {
+	lines := []string{"\u2067 שלום לכולם, \u2069 \u2067 מה שלומכם היום? \u2069"}
+	expected := []string{"\u2067 שלום לכולם, \u2069", "\u2067 מה שלומכם היום? \u2069"}
+
+	actual := wrapLines(lines, 20, 2)
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d: %q", len(expected), len(actual), actual)
+	}
+
+	for i, line := range expected {
+		if actual[i] != line {
+			t.Errorf("Expected line %d to be %q, got %q", i+1, line, actual[i])
+		}
+	}
+}
+
+func TestWrapLinesMaxLines(t *testing.T) {
+	cases := []struct {
+		lines    []string
+		maxLines int
+	}{
+		{[]string{"- Where were you last night? We waited for hours.", "- I was at home with my brother, watching the game."}, 3},
+		{[]string{"- Where were you last night? We waited for hours.", "- I was at home with my brother, watching the game."}, 2},
+		{[]string{"- Where were you last night? We waited for hours.", "- At home.", "- With whom? Tell me, we waited for hours."}, 4},
+		{[]string{"- Where were you last night? We waited for hours.", "- At home.", "- With whom? Tell me, we waited for hours."}, 3},
+		{[]string{"I went to the store to buy some milk and some bread, but they were all closed for the day."}, 2},
+	}
+
+	for i, c := range cases {
+		actual := wrapLines(c.lines, 42, c.maxLines)
+		if len(actual) > c.maxLines {
+			t.Errorf("Expected at most %d lines (case %d), got %d: %q", c.maxLines, i, len(actual), actual)
+		}
+
+		turns := 0
+		for _, line := range actual {
+			if isDialogueLine(line) {
+				turns++
+			}
+		}
+		if turns != len(dialogueTurns(c.lines)) && isDialogueLine(c.lines[0]) {
+			t.Errorf("Expected each dialogue turn to start a line (case %d), got %q", i, actual)
+		}
+	}
+
+	// Entries with more turns than lines get a line per turn
+	actual := wrapLines([]string{"- Where?", "- Home.", "- Why?"}, 42, 2)
+	if len(actual) != 3 {
+		t.Errorf("Expected a line per dialogue turn, got %q", actual)
+	}
+}